@@ -1,11 +1,22 @@
 package cmd
 
 import (
-	"github.com/google/uuid"
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
+	"github.com/bavix/vakeel/internal/app"
 	"github.com/bavix/vakeel/internal/build"
 	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/identity"
+	"github.com/bavix/vakeel/internal/infra/led"
+	"github.com/bavix/vakeel/internal/infra/transport"
 	"github.com/bavix/vakeel/pkg/ctxid"
 )
 
@@ -25,30 +36,282 @@ func init() {
 		// It creates a new builder with the configuration and calls the AgentApp method of the builder.
 		// The AgentApp method establishes a connection to the Vakeel server and starts sending update requests.
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			// Create a new context with the ID value from the configuration.
-			ctx := ctxid.WithID(cmd.Context(), cfg.ID)
+			// Apply the configuration file and the environment to the flags.
+			if err := loadConfig(cmd, cfg); err != nil {
+				return err
+			}
 
 			// Create a new builder with the configuration.
 			builder := build.New(cfg)
 
+			// Resolve the agent IDs from the configuration or the identity source.
+			identities, err := builder.Identities()
+			if err != nil {
+				return err
+			}
+
+			// Create a new context with the ID values and the logger.
+			ctx, err := builder.Logger(ctxid.WithIDs(cmd.Context(), config.UUIDs(identities)...))
+			if err != nil {
+				return err
+			}
+
 			// Call the AgentApp method of the builder and pass the context of the command.
+			// The configuration is reloaded on SIGHUP while the agent is running.
 			// The AgentApp method returns an error if the connection or the update service call fails.
-			return builder.AgentApp(builder.Logger(ctx))
+			return builder.AgentApp(ctx, watchReload(ctx, cmd, cfg))
 		},
 	}
 
+	// Define the flags of the agent command.
+	agentFlags(agentCmd.Flags(), cfg)
+
+	// Add the agent command to the root command.
+	rootCmd.AddCommand(agentCmd)
+}
+
+// agentFlags defines the flags of the agent command on the given flag set.
+//
+// The flags are bound to the fields of the given configuration.
+// They are defined again on a new flag set when the configuration is reloaded.
+func agentFlags(flags *pflag.FlagSet, cfg *config.Config) {
+	// Define the flags of the connection to the server and of the agent IDs.
+	connectionFlags(flags, cfg)
+
+	// Set the default value of the interval flag to 15 seconds.
+	// The agent sends an update request to the server every interval.
+	flags.
+		DurationVar(&cfg.Interval, "interval", 15*time.Second, "Interval between update requests sent to the server.")
+
+	// Set the default value of the retry interval flag to 1 second.
+	// The jittered backoff delay is added on top of it.
+	flags.
+		DurationVar(&cfg.RetryInterval, "retry-interval", time.Second, "Minimum delay before reconnecting to the server.")
+
+	// The schedule flag has no default value, the agent IDs send update requests at any time.
+	// The flag can be repeated to schedule several IDs.
+	flags.
+		StringArrayVar(&cfg.Schedules, "schedule", nil, "Window in which an agent ID sends update requests, "+
+			"i.e. <uuid>=<cron expression>, e.g. <uuid>='CRON_TZ=Europe/Berlin * 9-17 * * MON-FRI'. "+
+			"The ID is left out of the update requests outside its window. Can be repeated.")
+
+	// Disable the phase offset by default.
+	flags.
+		BoolVar(&cfg.Phase, "phase", false,
+			"Spread update requests over the interval using an offset derived from the agent ID.")
+
+	// Set the default values of the reconnection policy flags.
+	// The delay before reconnecting grows from 1 second up to 2 minutes and starts over
+	// once a stream has been healthy for a minute.
+	flags.
+		DurationVar(&cfg.Backoff.Initial, "backoff-initial", time.Second, "Initial delay before reconnecting to the server.")
+	flags.
+		DurationVar(&cfg.Backoff.Max, "backoff-max", 2*time.Minute, "Maximum delay before reconnecting to the server.")
+	flags.
+		Float64Var(&cfg.Backoff.Multiplier, "backoff-multiplier", 2, "Growth factor of the delay between reconnection attempts.")
+	flags.
+		DurationVar(&cfg.Backoff.ResetAfter, "backoff-reset", time.Minute,
+			"Stream lifetime after which the reconnection delay starts over.")
+
+	// Set the default value of the drain timeout flag to 5 seconds.
+	flags.
+		DurationVar(&cfg.DrainTimeout, "drain-timeout", 5*time.Second,
+			"Maximum time to wait for the server to acknowledge the stream close on shutdown.")
+
+	// The metrics endpoint is disabled by default.
+	flags.
+		StringVar(&cfg.MetricsListen, "metrics-listen", "", "Address of the Prometheus metrics endpoint, "+
+			"e.g. 127.0.0.1:9643. The metrics are served on /metrics, they are disabled if it is empty.")
+
+	// The OpenTelemetry export is disabled by default.
+	flags.
+		StringVar(&cfg.OTLP.Endpoint, "otlp-endpoint", "", "Address of the OTLP gRPC receiver the traces and "+
+			"the metrics are exported to, e.g. localhost:4317. The export is disabled if it is empty.")
+	flags.
+		BoolVar(&cfg.OTLP.Insecure, "otlp-insecure", false, "Connect to the OTLP receiver without TLS.")
+
+	// The hooks are disabled by default.
+	// They run with a timeout and at most once a minute each, so that a flapping connection does not flood the host.
+	flags.
+		StringVar(&cfg.Hooks.Connect, "hook-connect", "", "Shell command run once a stream to the server has been established.")
+	flags.
+		StringVar(&cfg.Hooks.Disconnect, "hook-disconnect", "", "Shell command run once the stream to the server failed.")
+	flags.
+		StringVar(&cfg.Hooks.Outage, "hook-outage", "", "Shell command run once the server has been unreachable "+
+			"for longer than --hook-outage-after.")
+	flags.
+		DurationVar(&cfg.Hooks.OutageAfter, "hook-outage-after", 5*time.Minute,
+			"Time the server must be unreachable before the outage hook is run.")
+	flags.
+		DurationVar(&cfg.Hooks.Timeout, "hook-timeout", 30*time.Second, "Maximum run time of a hook, it is killed afterwards.")
+	flags.
+		DurationVar(&cfg.Hooks.MinInterval, "hook-min-interval", time.Minute,
+			"Minimum time between two runs of the same hook, the events that occur meanwhile are skipped.")
+
+	// The status LED is disabled by default.
+	flags.
+		StringVar(&cfg.LED.Name, "led", "", "Name of the LED under --led-root that shows the connection state, "+
+			"e.g. green:status. It is solid while connected, blinks while reconnecting and is disabled if it is empty.")
+	flags.
+		StringVar(&cfg.LED.Root, "led-root", led.DefaultRoot, "Directory of the LED class devices.")
+
+	// The history takes at most twice its maximum size in the state directory.
+	flags.
+		IntVar(&cfg.HistoryMaxSize, "history-max-size", config.DefaultHistoryMaxSize(), "Size of the connectivity "+
+			"history in the state directory in kilobytes after which it is rotated. The history is disabled if it is 0.")
+
+	// Set the default value of the control socket flag.
+	controlFlags(flags, cfg)
+
+	// Set the default values of the logging flags.
+	logFlags(flags, &cfg.Log)
+}
+
+// connectionFlags defines the flags of the connection to the server and of the agent IDs
+// on the given flag set, they are shared by the commands that talk to the server.
+//
+// The flags are bound to the fields of the given configuration.
+func connectionFlags(flags *pflag.FlagSet, cfg *config.Config) {
 	// Set the default value of the host flag to "127.0.0.1".
-	agentCmd.Flags().
+	flags.
 		StringVarP(&cfg.Host, "host", "H", "127.0.0.1", "Host for agent, i.e. the IP address of the Vakeel server.")
 
 	// Set the default value of the port flag to 4643.
-	agentCmd.Flags().
+	flags.
 		IntVarP(&cfg.Port, "port", "p", 4643, "Port for agent, i.e. the port number of the Vakeel server.")
 
-	// Set the default value of the id flag to uuid.Nil.String().
-	agentCmd.Flags().
-		StringVar(&cfg.ID, "id", uuid.Nil.String(), "ID of agent, i.e. the UUID of the Vakeel agent.")
+	// The id flag has no default value.
+	// If it is omitted, a stable ID is derived from the identity source.
+	// The flag can be repeated to report several IDs on a single stream.
+	flags.
+		StringArrayVar(&cfg.IDs, "id", nil, "ID of agent, i.e. the UUID of the Vakeel agent, "+
+			"optionally followed by a label for logs, i.e. <uuid>=<label>. Can be repeated. "+
+			"If not provided, a stable ID is derived from --id-source.")
 
-	// Add the agent command to the root command.
-	rootCmd.AddCommand(agentCmd)
+	// Set the default values of the transport security flags.
+	// TLS with the system roots is used unless plaintext is requested explicitly.
+	flags.
+		BoolVar(&cfg.TLS.Plaintext, "plaintext", false, "Connect to the server without TLS.")
+	flags.
+		StringVar(&cfg.TLS.CAFile, "ca-file", "", "PEM bundle of the CAs trusted to sign the server certificate. "+
+			"The system roots are used if it is empty.")
+	flags.
+		StringVar(&cfg.TLS.ServerName, "server-name", "", "Server name used for SNI and certificate verification.")
+	flags.
+		StringArrayVar(&cfg.TLS.Pins, "pin", nil, "SPKI pin of the server certificate, i.e. sha256/<base64>. Can be repeated.")
+	flags.
+		StringVar(&cfg.TLS.CertFile, "cert-file", "", "PEM-encoded client certificate for mutual TLS. "+
+			"It is reloaded on the next reconnect when the file changes.")
+	flags.
+		StringVar(&cfg.TLS.KeyFile, "key-file", "", "PEM-encoded private key of the client certificate.")
+	flags.
+		StringVar(&cfg.TLS.Token, "token", "", "Auth token sent to the server as a bearer token. "+
+			"It is visible in the process list, prefer --token-file or the "+transport.TokenEnv+" environment variable.")
+	flags.
+		StringVar(&cfg.TLS.TokenFile, "token-file", "", "File with the auth token. "+
+			"It must not be accessible by group or others.")
+	flags.
+		DurationVar(&cfg.TLS.CertExpiryWarning, "cert-expiry-warning", 24*time.Hour,
+			"How long before the expiry of the client certificate a warning is logged.")
+
+	// Set the default values of the identity flags.
+	// They are used to derive a stable agent ID when the id flag is omitted.
+	flags.
+		StringVar(&cfg.IDSource, "id-source", string(identity.SourceAuto),
+			"Source of the agent ID when --id is omitted: auto, machine-id or state.")
+	flags.
+		StringVar(&cfg.IDNamespace, "id-namespace", identity.DefaultNamespace,
+			"Namespace UUID of the agent ID derived from the machine ID.")
+	flags.
+		StringVar(&cfg.StateDir, "state-dir", config.DefaultStateDir(), "Directory where the agent keeps its persistent state.")
+
+	// Set the default values of the UCI flags.
+	// The OpenWrt init script starts one agent per "agent" section of the UCI configuration.
+	flags.
+		StringVar(&cfg.UCIFile, "uci-file", config.DefaultUCIFile, "Path to the UCI configuration file.")
+	flags.
+		StringVar(&cfg.UCISection, "uci-section", "", "UCI section of the agent, e.g. main or @agent[0]. "+
+			"The UCI configuration is not read if it is empty.")
+}
+
+// watchReload reloads the configuration of the agent command on every SIGHUP until the context is cancelled.
+//
+// The configuration is loaded from the same command line, configuration files and environment
+// as the initial one. A configuration that cannot be loaded is logged and skipped.
+//
+// Parameters:
+//   - ctx: The context that stops the watcher, it provides the logger.
+//   - cmd: The agent command.
+//   - cfg: The initial configuration of the command.
+//
+// Returns:
+//   - The channel of the reloaded configurations.
+func watchReload(ctx context.Context, cmd *cobra.Command, cfg *config.Config) <-chan *config.Config {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP)
+
+	reloads := make(chan *config.Config)
+
+	go func() {
+		defer signal.Stop(signals)
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-signals:
+			}
+
+			zerolog.Ctx(ctx).Info().Msg("reloading configuration")
+
+			next, err := reloadConfig(cmd, cfg)
+			if err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Str(app.EventFieldName, app.EventReloadFailed).
+					Msg("failed to reload configuration, keeping the current one")
+
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case reloads <- next:
+			}
+		}
+	}()
+
+	return reloads
+}
+
+// reloadConfig loads the configuration of the agent command again.
+//
+// A new flag set is parsed from the flags set on the command line, so that the
+// values of the configuration files and the environment applied by the initial
+// load do not take precedence over the reloaded ones.
+//
+// Parameters:
+//   - cmd: The agent command.
+//   - cfg: The initial configuration of the command.
+//
+// Returns:
+//   - The reloaded configuration.
+//   - An error if the configuration cannot be loaded.
+func reloadConfig(cmd *cobra.Command, cfg *config.Config) (*config.Config, error) {
+	next := &config.Config{}
+
+	flags := pflag.NewFlagSet(cmd.Name(), pflag.ContinueOnError)
+	agentFlags(flags, next)
+
+	if err := flags.Parse(cfg.Sources.Args); err != nil {
+		return nil, err
+	}
+
+	// The UCI flags are not part of the recorded flags, they are only set on the command line.
+	next.UCIFile, next.UCISection = cfg.UCIFile, cfg.UCISection
+
+	if err := loadFlags(flags, next); err != nil {
+		return nil, err
+	}
+
+	return next, nil
 }
@@ -0,0 +1,150 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/build"
+	"github.com/bavix/vakeel/internal/config"
+)
+
+// errChecksFailed is the error returned when a check of the doctor failed.
+var errChecksFailed = errors.New("checks failed")
+
+// errDoctorTimeout is the error returned when the timeout of the checks is not positive.
+var errDoctorTimeout = errors.New("--timeout must be a positive duration, e.g. 5s")
+
+// doctorCheck is the JSON representation of the outcome of a check.
+type doctorCheck struct {
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	Detail     string `json:"detail,omitempty"`
+	Hint       string `json:"hint,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+// init registers the doctor command to the root command.
+//
+// The doctor command walks through the checklist of an agent that silently fails:
+// the connection to the server and the service installed by the register command.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	var (
+		// timeout is the maximum run time of a check set by the timeout flag.
+		timeout time.Duration
+		// asJSON is set by the json flag.
+		asJSON bool
+	)
+
+	// Create a new doctor command.
+	doctorCmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Check the connection to the server and the installation of the agent",
+		Long: "Run the checks step by step: DNS resolution of --host, TCP reachability, TLS handshake, " +
+			"opening an update stream, and the service written by register being installed, enabled, " +
+			"running this binary and reporting the agent IDs. The update stream is closed without " +
+			"reporting the agent IDs. Exits with a non-zero code if a check fails.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// Apply the configuration file and the environment to the flags,
+			// so that the checks use the settings of the agent.
+			if err := loadConfig(cmd, cfg); err != nil {
+				return err
+			}
+
+			// Check the timeout after the other layers, they may have set it as well.
+			if timeout <= 0 {
+				return errDoctorTimeout
+			}
+
+			// Create a new builder with the configuration.
+			builder := build.New(cfg)
+
+			// The logger is created without the agent IDs, the doctor never generates the agent ID.
+			ctx, err := builder.Logger(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			results, err := builder.DoctorApp(ctx, timeout)
+			if err != nil {
+				return err
+			}
+
+			if err := printChecks(cmd.OutOrStdout(), results, asJSON); err != nil {
+				return err
+			}
+
+			failed := 0
+
+			for _, result := range results {
+				if result.Status == app.CheckFailed {
+					failed++
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("%w: %d of %d", errChecksFailed, failed, len(results))
+			}
+
+			return nil
+		},
+	}
+
+	// Define the flags of the doctor command.
+	connectionFlags(doctorCmd.Flags(), cfg)
+	doctorCmd.Flags().
+		DurationVar(&timeout, "timeout", 5*time.Second, "Maximum run time of a check.") //nolint:mnd
+	doctorCmd.Flags().
+		BoolVar(&asJSON, "json", false, "Print the outcomes of the checks as JSON.")
+	logFlags(doctorCmd.Flags(), &cfg.Log)
+
+	// The timeout and the output format are given with the command, not configured for the agent.
+	config.MarkLocal(doctorCmd.Flags(), "timeout", "json")
+
+	// Add the doctor command to the root command.
+	rootCmd.AddCommand(doctorCmd)
+}
+
+// printChecks writes the outcomes of the checks as JSON or as a human-readable list with the hints.
+func printChecks(out io.Writer, results []app.CheckResult, asJSON bool) error {
+	if asJSON {
+		checks := make([]doctorCheck, 0, len(results))
+		for _, result := range results {
+			checks = append(checks, doctorCheck{
+				Name:       result.Name,
+				Status:     string(result.Status),
+				Detail:     result.Detail,
+				Hint:       result.Hint,
+				DurationMS: result.Duration.Milliseconds(),
+			})
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(checks)
+	}
+
+	table := newTable(out)
+
+	for _, result := range results {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", strings.ToUpper(string(result.Status)), result.Name, result.Detail)
+
+		if result.Hint != "" {
+			fmt.Fprintf(table, "\t\thint: %s\n", result.Hint)
+		}
+	}
+
+	return table.Flush()
+}
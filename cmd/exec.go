@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/build"
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+// errExecDurations is the error returned when the durations of exec are invalid.
+var errExecDurations = errors.New("--ping-timeout must be positive, --job-timeout and --heartbeat must not be negative")
+
+// init registers the exec command to the root command.
+//
+// The exec command runs a job, e.g. a backup, and reports the agent IDs only if the job succeeds,
+// so that the server raises an alert once a job fails or stops running.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	// job is the job to run, its settings are set by the flags.
+	var job build.Job
+
+	// Create a new exec command.
+	execCmd := &cobra.Command{
+		Use:   "exec [flags] -- <command> [args...]",
+		Short: "Run a command and report the agent IDs if it succeeds",
+		Long: "Run the command, forward its standard output, standard error and exit code, and send an update request " +
+			"with the agent IDs only if it exits successfully within --job-timeout. With --heartbeat the heartbeat IDs " +
+			"are reported while the command runs, so that a stuck job can be told from a finished one. " +
+			"The messages of vakeel are written to the standard error.",
+		Args:         cobra.MinimumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Apply the configuration file and the environment to the flags.
+			if err := loadConfig(cmd, cfg); err != nil {
+				return err
+			}
+
+			// Check the durations after the other layers, they may have set them as well.
+			if job.PingTimeout <= 0 || job.Timeout < 0 || job.Heartbeat < 0 {
+				return errExecDurations
+			}
+
+			// The standard output belongs to the job.
+			cfg.Log.Stderr = true
+
+			// Create a new builder with the configuration.
+			builder := build.New(cfg)
+
+			// Resolve the agent IDs from the configuration or the identity source.
+			identities, err := builder.Identities()
+			if err != nil {
+				return err
+			}
+
+			// Create a new context with the ID values and the logger.
+			ctx, err := builder.Logger(ctxid.WithIDs(cmd.Context(), config.UUIDs(identities)...))
+			if err != nil {
+				return err
+			}
+
+			job.Args = args
+			job.Stdin, job.Stdout, job.Stderr = cmd.InOrStdin(), cmd.OutOrStdout(), cmd.ErrOrStderr()
+
+			code, err := builder.ExecApp(ctx, job)
+			if err != nil {
+				return err
+			}
+
+			// The job and the report have been logged, only the exit code is left.
+			if code != 0 {
+				cmd.SilenceErrors = true
+
+				return exitCode(code)
+			}
+
+			return nil
+		},
+	}
+
+	// The flags after the command are passed to it.
+	execCmd.Flags().SetInterspersed(false)
+
+	// Define the flags of the exec command.
+	connectionFlags(execCmd.Flags(), cfg)
+	execCmd.Flags().
+		DurationVar(&job.Timeout, "job-timeout", 0, "Maximum run time of the command, it is stopped afterwards "+
+			"and nothing is reported. No limit if it is 0.")
+	execCmd.Flags().
+		DurationVar(&job.PingTimeout, "ping-timeout", 10*time.Second, "Maximum time to wait for the server "+ //nolint:mnd
+			"to acknowledge an update request.")
+	execCmd.Flags().
+		DurationVar(&job.Heartbeat, "heartbeat", 0, "Interval between the update requests with the --heartbeat-id "+
+			"values sent while the command runs. Disabled if it is 0.")
+	execCmd.Flags().
+		StringArrayVar(&job.HeartbeatIDs, "heartbeat-id", nil, "ID reported while the command runs, "+
+			"optionally followed by a label, i.e. <uuid>=<label>. Can be repeated. Required by --heartbeat, "+
+			"it must not be one of the --id values, which a stuck job would keep up otherwise.")
+	logFlags(execCmd.Flags(), &cfg.Log)
+
+	// The settings of the job are given with the job, e.g. in the crontab, not configured for every job.
+	config.MarkLocal(execCmd.Flags(), "job-timeout", "heartbeat", "heartbeat-id")
+
+	// Add the exec command to the root command.
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,180 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/history"
+)
+
+// errHistoryWindow is the error returned when the window of the history is not positive.
+var errHistoryWindow = errors.New("--since must be a positive duration, e.g. 24h")
+
+// historyReport is the JSON representation of the connectivity history.
+type historyReport struct {
+	From               time.Time       `json:"from"`
+	To                 time.Time       `json:"to"`
+	Availability       *float64        `json:"availability_percent"`
+	UptimeSeconds      float64         `json:"uptime_seconds"`
+	DowntimeSeconds    float64         `json:"downtime_seconds"`
+	NotRecordedSeconds float64         `json:"not_recorded_seconds"`
+	Outages            []historyOutage `json:"outages"`
+}
+
+// historyOutage is the JSON representation of an outage.
+type historyOutage struct {
+	Start           time.Time `json:"start"`
+	End             time.Time `json:"end"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Code            string    `json:"code,omitempty"`
+	Ongoing         bool      `json:"ongoing"`
+}
+
+// init registers the history command to the root command.
+//
+// The history command reads the connectivity history the agent keeps in the state directory
+// and prints the outages, the total downtime and the availability within a window.
+// It does not need the agent to be running.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	var (
+		// since is the length of the window ending now, set by the since flag.
+		since time.Duration
+		// asJSON is set by the json flag.
+		asJSON bool
+	)
+
+	// Create a new history command.
+	historyCmd := &cobra.Command{
+		Use:   "history",
+		Short: "Show the outages and the availability of the agent",
+		Long: "Print the outages of the connection to the server, the total downtime and the availability " +
+			"recorded by the agent within the window. The time the agent was not running is left out.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// Apply the configuration file and the environment to the flags,
+			// so that the command reads the history of the configured state directory.
+			if err := loadConfig(cmd, cfg); err != nil {
+				return err
+			}
+
+			if since <= 0 {
+				return errHistoryWindow
+			}
+
+			records, err := history.NewJournal(cfg.StateDir, cfg.Instance, 0).Records()
+			if err != nil {
+				return err
+			}
+
+			now := time.Now()
+			report := history.Summarize(records, now.Add(-since), now)
+
+			return printHistory(cmd.OutOrStdout(), report, asJSON)
+		},
+	}
+
+	// Define the flags of the history command.
+	historyCmd.Flags().
+		StringVar(&cfg.StateDir, "state-dir", config.DefaultStateDir(), "Directory where the agent keeps its persistent state.")
+	instanceFlags(historyCmd.Flags(), cfg)
+	historyCmd.Flags().
+		DurationVar(&since, "since", 24*time.Hour, "Length of the window ending now, e.g. 168h for a week.") //nolint:mnd
+	historyCmd.Flags().
+		BoolVar(&asJSON, "json", false, "Print the history as JSON.")
+
+	// The window and the output format are given with the command, not configured for the agent.
+	config.MarkLocal(historyCmd.Flags(), "since", "json")
+
+	// Add the history command to the root command.
+	rootCmd.AddCommand(historyCmd)
+}
+
+// printHistory writes the connectivity history as JSON or as a human-readable summary.
+func printHistory(out io.Writer, report history.Report, asJSON bool) error {
+	availability, known := report.Availability()
+
+	if asJSON {
+		result := historyReport{
+			From:               report.From,
+			To:                 report.To,
+			UptimeSeconds:      report.Uptime.Seconds(),
+			DowntimeSeconds:    report.Downtime.Seconds(),
+			NotRecordedSeconds: report.Unknown.Seconds(),
+			Outages:            make([]historyOutage, 0, len(report.Outages)),
+		}
+
+		if known {
+			result.Availability = &availability
+		}
+
+		for _, outage := range report.Outages {
+			result.Outages = append(result.Outages, historyOutage{
+				Start:           outage.Start,
+				End:             outage.End,
+				DurationSeconds: outage.Duration().Seconds(),
+				Code:            outage.Code,
+				Ongoing:         outage.Ongoing,
+			})
+		}
+
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(result)
+	}
+
+	table := newTable(out)
+
+	fmt.Fprintf(table, "Window:\t%s - %s (%s)\n",
+		formatTime(report.From), formatTime(report.To), report.To.Sub(report.From).Round(time.Second))
+
+	if known {
+		fmt.Fprintf(table, "Availability:\t%.3f%%\n", availability)
+	} else {
+		fmt.Fprintf(table, "Availability:\tunknown, the agent was not running\n")
+	}
+
+	fmt.Fprintf(table, "Downtime:\t%s in %d outages\n", report.Downtime.Round(time.Second), len(report.Outages))
+
+	if report.Unknown > 0 {
+		fmt.Fprintf(table, "Not recorded:\t%s\n", report.Unknown.Round(time.Second))
+	}
+
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	if len(report.Outages) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(out)
+
+	table = newTable(out)
+	fmt.Fprintf(table, "START\tEND\tDURATION\tERROR\n")
+
+	for _, outage := range report.Outages {
+		end := formatTime(outage.End)
+		if outage.Ongoing {
+			end = "ongoing"
+		}
+
+		code := outage.Code
+		if code == "" {
+			code = "-"
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\n", formatTime(outage.Start), end, outage.Duration().Round(time.Second), code)
+	}
+
+	return table.Flush()
+}
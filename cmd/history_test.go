@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/spf13/pflag"
+
+	"github.com/bavix/vakeel/internal/config"
+)
+
+// executeCommand runs the command line with the flags of the commands reset to their defaults.
+func executeCommand(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	for _, command := range rootCmd.Commands() {
+		command.Flags().VisitAll(func(flag *pflag.Flag) {
+			if err := flag.Value.Set(flag.DefValue); err != nil {
+				t.Fatal(err)
+			}
+
+			flag.Changed = false
+		})
+	}
+
+	var out bytes.Buffer
+
+	rootCmd.SetOut(&out)
+	rootCmd.SetErr(&out)
+	rootCmd.SetArgs(args)
+
+	err := rootCmd.Execute()
+
+	return out.String(), err
+}
+
+func TestHistoryWindow(t *testing.T) {
+	t.Setenv(config.FileEnv, "")
+
+	dir := t.TempDir()
+
+	for _, since := range []string{"0s", "-1h"} {
+		if _, err := executeCommand(t, "history", "--state-dir", dir, "--since", since); !errors.Is(err, errHistoryWindow) {
+			t.Errorf("history --since %s = %v, want %v", since, err, errHistoryWindow)
+		}
+	}
+
+	// The window and the output format only come from the command line.
+	t.Setenv("VAKEEL_SINCE", "-1h")
+	t.Setenv("VAKEEL_JSON", "true")
+
+	out, err := executeCommand(t, "history", "--state-dir", dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(out, "(24h0m0s)") {
+		t.Fatalf("history printed %q, want the default window as text", out)
+	}
+}
@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/config"
+)
+
+// init registers the log-level command to the root command.
+//
+// The log-level command prints or changes the log level of the running agent over the control socket.
+// The change lasts until the agent is restarted.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	// Create a new log-level command.
+	logLevelCmd := &cobra.Command{
+		Use:   "log-level [level]",
+		Short: "Print or change the log level of the running agent",
+		Long: "Print the log level of the running agent, or change it to trace, debug, info, warn, error or disabled. " +
+			"The change lasts until the agent is restarted.",
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			client, err := controlClient(cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), controlTimeout)
+			defer cancel()
+
+			if len(args) == 0 {
+				status, err := client.Status(ctx)
+				if err != nil {
+					return err
+				}
+
+				_, err = fmt.Fprintln(cmd.OutOrStdout(), status.LogLevel)
+
+				return err
+			}
+
+			status, err := client.SetLogLevel(ctx, args[0])
+			if err != nil {
+				return err
+			}
+
+			_, err = fmt.Fprintln(cmd.OutOrStdout(), status.LogLevel)
+
+			return err
+		},
+	}
+
+	// Define the flags of the log-level command.
+	controlFlags(logLevelCmd.Flags(), cfg)
+
+	// Add the log-level command to the root command.
+	rootCmd.AddCommand(logLevelCmd)
+}
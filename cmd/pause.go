@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/config"
+)
+
+// errPauseDuration is the error returned when the duration of the pause is not positive.
+var errPauseDuration = errors.New("--for must be a positive duration, e.g. 2h")
+
+// init registers the pause command to the root command.
+//
+// The pause command stops the update requests of the running agent for a while, e.g. during
+// planned maintenance, without stopping the agent. The pause survives restarts of the agent.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	var (
+		// duration is the duration of the pause set by the for flag.
+		duration time.Duration
+		// ids are the agent IDs to pause set by the id flag, all of them if it is empty.
+		ids []string
+	)
+
+	// Create a new pause command.
+	pauseCmd := &cobra.Command{
+		Use:   "pause --for <duration>",
+		Short: "Pause the update requests of the running agent",
+		Long: "Stop sending update requests for some or all IDs of the running agent until the duration elapses, " +
+			"e.g. during planned maintenance. The agent resumes on its own, the pause survives restarts of the agent.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			if duration <= 0 {
+				return errPauseDuration
+			}
+
+			client, err := controlClient(cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), controlTimeout)
+			defer cancel()
+
+			status, err := client.Pause(ctx, ids, time.Now().Add(duration))
+			if err != nil {
+				return err
+			}
+
+			table := newTable(cmd.OutOrStdout())
+			printPauses(table, time.Now(), status.Pauses)
+
+			return table.Flush()
+		},
+	}
+
+	// Define the flags of the pause command.
+	controlFlags(pauseCmd.Flags(), cfg)
+	pauseCmd.Flags().
+		DurationVar(&duration, "for", 0, "How long the update requests are paused, e.g. 2h.")
+	pauseCmd.Flags().
+		StringArrayVar(&ids, "id", nil, "Agent ID to pause. Can be repeated. All the IDs are paused if it is omitted.")
+
+	_ = pauseCmd.MarkFlagRequired("for")
+
+	// The IDs to pause are not the IDs of the agent, which the configuration files and the environment set.
+	config.MarkLocal(pauseCmd.Flags(), "for", "id")
+
+	// Add the pause command to the root command.
+	rootCmd.AddCommand(pauseCmd)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/build"
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+// errPingTimeout is the error returned when the timeout of the ping is not positive.
+var errPingTimeout = errors.New("--ping-timeout must be a positive duration, e.g. 10s")
+
+// init registers the ping command to the root command.
+//
+// The ping command reports the agent IDs to the server once and exits, for the hosts that
+// do not run the agent, e.g. after a cron job or at boot.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	// timeout is the maximum time to wait for the server set by the ping-timeout flag.
+	var timeout time.Duration
+
+	// Create a new ping command.
+	pingCmd := &cobra.Command{
+		Use:   "ping",
+		Short: "Report the agent IDs to the server once",
+		Long: "Open an update stream, send a single update request with the agent IDs and wait for the server " +
+			"to acknowledge it. Exits with a non-zero code if the server does not acknowledge it within --ping-timeout.",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			// Apply the configuration file and the environment to the flags.
+			if err := loadConfig(cmd, cfg); err != nil {
+				return err
+			}
+
+			// Check the timeout after the other layers, they may have set it as well.
+			if timeout <= 0 {
+				return errPingTimeout
+			}
+
+			// Create a new builder with the configuration.
+			builder := build.New(cfg)
+
+			// Resolve the agent IDs from the configuration or the identity source.
+			identities, err := builder.Identities()
+			if err != nil {
+				return err
+			}
+
+			// Create a new context with the ID values and the logger.
+			ctx, err := builder.Logger(ctxid.WithIDs(cmd.Context(), config.UUIDs(identities)...))
+			if err != nil {
+				return err
+			}
+
+			return builder.PingApp(ctx, timeout)
+		},
+	}
+
+	// Define the flags of the ping command.
+	connectionFlags(pingCmd.Flags(), cfg)
+	pingCmd.Flags().
+		DurationVar(&timeout, "ping-timeout", 10*time.Second, "Maximum time to wait for the server "+ //nolint:mnd
+			"to acknowledge the update request.")
+	logFlags(pingCmd.Flags(), &cfg.Log)
+
+	// Add the ping command to the root command.
+	rootCmd.AddCommand(pingCmd)
+}
@@ -3,7 +3,6 @@
 package cmd
 
 import (
-	"github.com/google/uuid"
 	"github.com/spf13/cobra"
 
 	"github.com/bavix/vakeel/internal/build"
@@ -24,32 +23,38 @@ func init() {
 		Short: "Register the agent with the server",
 		Args:  cobra.MaximumNArgs(0),
 		RunE: func(cmd *cobra.Command, _ []string) error {
-			// Create a new context with the ID value from the configuration.
-			ctx := ctxid.WithID(cmd.Context(), cfg.ID)
+			// Apply the configuration file and the environment to the flags.
+			if err := loadConfig(cmd, cfg); err != nil {
+				return err
+			}
 
 			// Create a new builder with the configuration.
 			builder := build.New(cfg)
 
+			// Resolve the agent IDs from the configuration or the identity source.
+			identities, err := builder.Identities()
+			if err != nil {
+				return err
+			}
+
+			// Create a new context with the ID values and the logger.
+			ctx, err := builder.Logger(ctxid.WithIDs(cmd.Context(), config.UUIDs(identities)...))
+			if err != nil {
+				return err
+			}
+
 			// Call the AgentRegisterApp method of the builder and pass the context of the command.
 			// The AgentRegisterApp method registers the agent application with the server.
-			return builder.AgentRegisterApp(builder.Logger(ctx))
+			return builder.AgentRegisterApp(ctx)
 		},
 	}
 
-	// Set the default value of the host flag to "127.0.0.1".
-	registerCmd.Flags().
-		StringVarP(&cfg.Host, "host", "H", "127.0.0.1", "Host for agent, i.e. the IP address of the Vakeel server.")
-
-	// Set the default value of the port flag to 4643.
-	registerCmd.Flags().
-		IntVarP(&cfg.Port, "port", "p", 4643, "Port for agent, i.e. the port number of the Vakeel server.")
+	// Define the flags of the register command.
+	// They are the connection flags of the agent, the generated service passes them on to it.
+	connectionFlags(registerCmd.Flags(), cfg)
 
-	// Set the default value of the id flag to a new UUID.
-	// The flag is used to set the ID of the agent, i.e. the UUID of the Vakeel agent.
-	// The UUID is generated using uuid.New() and converted to a string using uuid.String().
-	registerCmd.Flags().
-		StringVar(&cfg.ID, "id", uuid.New().String(), "ID of agent, i.e. the UUID of the Vakeel agent."+
-			"If not provided, a new UUID will be generated.")
+	// Set the default values of the logging flags.
+	logFlags(registerCmd.Flags(), &cfg.Log)
 
 	// Add the register command to the root command.
 	rootCmd.AddCommand(registerCmd)
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"context"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/config"
+)
+
+// init registers the resume command to the root command.
+//
+// The resume command ends the pauses of the update requests of the running agent before they elapse.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	// ids are the agent IDs to resume set by the id flag, all of them if it is empty.
+	var ids []string
+
+	// Create a new resume command.
+	resumeCmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Resume the update requests of the running agent",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			client, err := controlClient(cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), controlTimeout)
+			defer cancel()
+
+			status, err := client.Resume(ctx, ids)
+			if err != nil {
+				return err
+			}
+
+			table := newTable(cmd.OutOrStdout())
+			printPauses(table, time.Now(), status.Pauses)
+
+			return table.Flush()
+		},
+	}
+
+	// Define the flags of the resume command.
+	controlFlags(resumeCmd.Flags(), cfg)
+	resumeCmd.Flags().
+		StringArrayVar(&ids, "id", nil, "Agent ID to resume. Can be repeated. All the IDs are resumed if it is omitted.")
+
+	// The IDs to resume are not the IDs of the agent, which the configuration files and the environment set.
+	config.MarkLocal(resumeCmd.Flags(), "id")
+
+	// Add the resume command to the root command.
+	rootCmd.AddCommand(resumeCmd)
+}
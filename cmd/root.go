@@ -2,9 +2,17 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
+	"regexp"
+	"strconv"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/logging"
 )
 
 var rootCmd = &cobra.Command{
@@ -12,8 +20,134 @@ var rootCmd = &cobra.Command{
 	Short: "Agent for vakeel-way",
 }
 
+// errInvalidInstance is the error returned when the instance name cannot be part of a file name.
+var errInvalidInstance = errors.New("instance name must only contain letters, digits, \"_\" and \"-\"")
+
+// instancePattern matches the valid instance names, e.g. the names of UCI sections.
+var instancePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// configFile is the path to the configuration file given with the config flag.
+var configFile string
+
+func init() {
+	// The configuration file is shared by all the commands.
+	// If the flag is not set, the VAKEEL_CONFIG environment variable or the default file is used.
+	rootCmd.PersistentFlags().
+		StringVar(&configFile, "config", "", "Path to the configuration file. "+
+			"Defaults to $"+config.FileEnv+" or "+config.DefaultFile+" if it exists.")
+}
+
+// exitCode is the error of a command that exits with the given code, e.g. the exit code of the job run by exec.
+type exitCode int
+
+// Error returns the message of the exit code.
+func (c exitCode) Error() string {
+	return "exit code " + strconv.Itoa(int(c))
+}
+
 func Execute(ctx context.Context) {
 	if err := rootCmd.ExecuteContext(ctx); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+
 		os.Exit(1)
 	}
 }
+
+// loadConfig applies the configuration files and the environment to the flags of the command.
+//
+// The flags set on the command line take precedence over the environment,
+// which takes precedence over the UCI section and the configuration file.
+func loadConfig(cmd *cobra.Command, cfg *config.Config) error {
+	return loadFlags(cmd.Flags(), cfg)
+}
+
+// loadFlags applies the configuration files and the environment to the given flags
+// bound to the configuration.
+func loadFlags(flags *pflag.FlagSet, cfg *config.Config) error {
+	path := configFile
+	if path == "" {
+		path = os.Getenv(config.FileEnv)
+	}
+
+	sources, err := config.Load(flags, config.Files{
+		Config:     path,
+		UCI:        cfg.UCIFile,
+		UCISection: cfg.UCISection,
+	}, knownSetting)
+	if err != nil {
+		return err
+	}
+
+	cfg.Sources = sources
+
+	// The instance name becomes part of file names.
+	if cfg.Instance != "" && !instancePattern.MatchString(cfg.Instance) {
+		return fmt.Errorf("%w, got %q", errInvalidInstance, cfg.Instance)
+	}
+
+	return nil
+}
+
+// logFlags defines the logging flags of a command on the given flag set.
+//
+// The flags are bound to the logging configuration.
+func logFlags(flags *pflag.FlagSet, cfg *config.Log) {
+	// The level is empty by default, so that the LOG_LEVEL environment variable is still honoured.
+	flags.
+		StringVar(&cfg.Level, "log-level", "", "Log level: trace, debug, info, warn, error or disabled. "+
+			"Defaults to $LOG_LEVEL or info.")
+
+	// Set the default value of the log format flag to console.
+	// Colors are only used when the output is a terminal.
+	flags.
+		StringVar(&cfg.Format, "log-format", logging.FormatConsole, "Log format: console, json or logfmt.")
+
+	// Set the default value of the log sink flag to stdout.
+	// The syslog and journald sinks receive structured fields, e.g. the agent IDs and the server.
+	flags.
+		StringVar(&cfg.Sink, "log-sink", logging.SinkStdout, "Where the log messages are written: "+
+			"stdout, syslog (RFC 5424 to "+logging.SyslogSocket+") or journald.")
+
+	// Set the default values of the log file flags.
+	// The defaults keep at most 2 MB of logs, which fits the tmpfs of small OpenWrt devices.
+	flags.
+		StringVar(&cfg.File, "log-file", "", "Path to the log file. The standard output is used if it is empty.")
+	flags.
+		IntVar(&cfg.MaxSize, "log-max-size", 1024, "Size of the log file in kilobytes after which it is rotated. "+
+			"Zero disables the rotation.")
+	flags.
+		IntVar(&cfg.MaxBackups, "log-max-backups", 1, "Number of rotated log files that are kept.")
+}
+
+// controlFlags defines the control socket flags of a command on the given flag set.
+//
+// The flags are shared by the agent, which serves the control socket, and the commands that talk to it.
+func controlFlags(flags *pflag.FlagSet, cfg *config.Config) {
+	flags.
+		StringVar(&cfg.ControlSocket, "control-socket", config.DefaultControlSocket(),
+			"Path to the control socket of the agent. The agent does not serve it if it is empty.")
+	instanceFlags(flags, cfg)
+}
+
+// instanceFlags defines the instance flag of a command on the given flag set.
+//
+// The flag is shared by the agent and the commands that read its control socket and its state files.
+func instanceFlags(flags *pflag.FlagSet, cfg *config.Config) {
+	flags.
+		StringVar(&cfg.Instance, "instance", "", "Name of the agent instance, e.g. the UCI section it runs for. "+
+			"It is appended to the control socket and the state files, so that several agents can run on the same host.")
+}
+
+// knownSetting reports whether any command has a flag with the given name that can be configured.
+func knownSetting(name string) bool {
+	for _, command := range rootCmd.Commands() {
+		if flag := command.Flags().Lookup(name); flag != nil && !config.IsLocal(flag) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/control"
+)
+
+// controlTimeout is the maximum time to wait for the agent to answer on the control socket.
+const controlTimeout = 5 * time.Second
+
+// init registers the status command to the root command.
+//
+// The status command asks the running agent for its state over the control socket.
+func init() {
+	// Create a new configuration object.
+	cfg := &config.Config{}
+
+	// asJSON is set by the json flag.
+	var asJSON bool
+
+	// Create a new status command.
+	statusCmd := &cobra.Command{
+		Use:   "status",
+		Short: "Show the state of the running agent",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			client, err := controlClient(cmd, cfg)
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithTimeout(cmd.Context(), controlTimeout)
+			defer cancel()
+
+			status, err := client.Status(ctx)
+			if err != nil {
+				return err
+			}
+
+			return printStatus(cmd.OutOrStdout(), status, asJSON)
+		},
+	}
+
+	// Define the flags of the status command.
+	controlFlags(statusCmd.Flags(), cfg)
+	statusCmd.Flags().
+		BoolVar(&asJSON, "json", false, "Print the status as JSON.")
+
+	// The output format is given with the command, not configured for the agent.
+	config.MarkLocal(statusCmd.Flags(), "json")
+
+	// Add the status command to the root command.
+	rootCmd.AddCommand(statusCmd)
+}
+
+// controlClient applies the configuration to the flags of the command and
+// returns the client of the control socket of the running agent.
+func controlClient(cmd *cobra.Command, cfg *config.Config) (*control.Client, error) {
+	// Apply the configuration file and the environment to the flags,
+	// so that the command finds the control socket configured for the agent.
+	if err := loadConfig(cmd, cfg); err != nil {
+		return nil, err
+	}
+
+	return control.NewClient(config.InstancePath(cfg.ControlSocket, cfg.Instance)), nil
+}
+
+// printStatus writes the status of the agent as JSON or as a human-readable summary.
+func printStatus(out io.Writer, status control.Status, asJSON bool) error {
+	if asJSON {
+		encoder := json.NewEncoder(out)
+		encoder.SetIndent("", "  ")
+
+		return encoder.Encode(status)
+	}
+
+	now := time.Now()
+	table := newTable(out)
+
+	fmt.Fprintf(table, "State:\t%s since %s (%s)\n", status.State, formatTime(status.Since), age(now, status.Since))
+
+	if status.BackoffSeconds > 0 {
+		fmt.Fprintf(table, "Backoff:\t%s\n", time.Duration(status.BackoffSeconds*float64(time.Second)).Round(time.Millisecond))
+	}
+
+	if status.LastSend != nil {
+		fmt.Fprintf(table, "Last send:\t%s (%s ago)\n", formatTime(*status.LastSend), age(now, *status.LastSend))
+	} else {
+		fmt.Fprintf(table, "Last send:\tnever\n")
+	}
+
+	if status.LastError != "" {
+		fmt.Fprintf(table, "Last error:\t%s\n", status.LastError)
+	}
+
+	for i, id := range status.IDs {
+		name := id.ID
+		if id.Label != "" {
+			name += " (" + id.Label + ")"
+		}
+
+		if i == 0 {
+			fmt.Fprintf(table, "IDs:\t%s\n", name)
+		} else {
+			fmt.Fprintf(table, "\t%s\n", name)
+		}
+	}
+
+	printPauses(table, now, status.Pauses)
+	fmt.Fprintf(table, "Log level:\t%s\n", status.LogLevel)
+	fmt.Fprintf(table, "Version:\t%s (pid %d)\n", status.Version, status.PID)
+
+	return table.Flush()
+}
+
+// printPauses writes the pauses of the update requests as rows of the status table.
+func printPauses(table io.Writer, now time.Time, pauses []control.Pause) {
+	if len(pauses) == 0 {
+		fmt.Fprintf(table, "Paused:\tno\n")
+
+		return
+	}
+
+	for i, pause := range pauses {
+		name := pause.ID
+		if name == "" {
+			name = "all IDs"
+		}
+
+		label := "\t"
+		if i == 0 {
+			label = "Paused:\t"
+		}
+
+		fmt.Fprintf(table, "%s%s until %s (%s left)\n",
+			label, name, formatTime(pause.Until), pause.Until.Sub(now).Round(time.Second))
+	}
+}
+
+// newTable returns the writer that aligns the columns of the rows separated by tabs.
+// It must be flushed once the rows have been written.
+func newTable(out io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(out, 0, 0, 2, ' ', 0) //nolint:mnd
+}
+
+// formatTime formats the time in the local time zone.
+func formatTime(t time.Time) string {
+	return t.Local().Format(time.DateTime)
+}
+
+// age returns the time elapsed since the given time, rounded to seconds.
+func age(now, since time.Time) time.Duration {
+	return now.Sub(since).Round(time.Second)
+}
@@ -2,9 +2,13 @@ package app
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 
 	apiv1 "github.com/bavix/apis/pkg/bavix/api/v1"
 	"github.com/bavix/apis/pkg/uuidconv"
@@ -12,108 +16,326 @@ import (
 	"github.com/bavix/vakeel/pkg/ctxid"
 )
 
-// duration is the duration between update requests sent by the agent.
-// It is set to 15 seconds.
+// Options holds the settings of the agent loop.
+type Options struct {
+	// Interval is the duration between update requests sent by the agent.
+	Interval time.Duration
+	// RetryInterval is the minimum delay before reconnecting to the server.
+	// The jittered backoff delay is added on top of it.
+	RetryInterval time.Duration
+	// Phase is the offset of the update requests within the interval.
+	// When it is set, update requests are aligned to the wall clock at
+	// multiples of the interval shifted by the phase. Zero disables the alignment.
+	Phase time.Duration
+	// Labels are the human-readable names of the agent IDs used in logs.
+	Labels map[uuid.UUID]string
+	// Schedules are the windows in which the agent IDs send update requests.
+	// An ID is left out of the update requests outside its window, the IDs without a schedule are always sent.
+	Schedules map[uuid.UUID]Schedule
+	// Backoff is the reconnection policy used between attempts.
+	Backoff *Backoff
+	// DrainTimeout is the maximum time to wait for the server to acknowledge the stream close.
+	// Once the agent is asked to stop, it also limits the wait for a stream that is being opened.
+	DrainTimeout time.Duration
+	// Metrics records the activity of the agent. Nil disables the recording.
+	Metrics Metrics
+	// Tracer creates the spans of the streams and the update requests. Nil disables tracing.
+	Tracer trace.Tracer
+	// Status is the shared state of the agent reported over the control socket. Nil disables the reporting.
+	Status *Status
+	// Pauses are the pauses of the update requests of the agent IDs. Nil pauses nothing.
+	Pauses *Pauses
+	// Reloads delivers the settings that replace the current ones while the agent is running.
+	// The current stream is kept. Nil disables reloading.
+	Reloads <-chan Reload
+}
+
+// Reload holds the settings that are applied to the running agent without reconnecting.
+type Reload struct {
+	// IDs are the agent IDs sent in every update request.
+	IDs []uuid.UUID
+	// Options are the new settings of the agent loop. Their Reloads channel is ignored.
+	Options Options
+}
+
+// ErrReconnect is the cause of the cancellation of the agent context when the agent
+// is stopped to reconnect to the server with a new configuration.
+var ErrReconnect = errors.New("reconnecting with the new configuration")
+
+// settings are the current settings of the agent loop.
+//
+// They are shared by the stream and the retries, so that a reload received by either applies to both.
+type settings struct {
+	// ids are the agent IDs sent in every update request.
+	ids []uuid.UUID
+	// opts are the settings of the agent loop.
+	opts Options
+}
+
+// apply replaces the settings with the reloaded ones, keeping the reloads channel.
+func (s *settings) apply(reload Reload) {
+	reloads := s.opts.Reloads
+
+	s.ids, s.opts = reload.IDs, reload.Options
+	s.opts.Reloads = reloads
+
+	s.opts.Status.configured(s.ids, s.opts.Labels)
+}
+
+// wait waits for the given duration or until the context is cancelled.
 //
-// Update requests are sent continuously to the server with this duration.
-// The agent will send an update request to the server every 15 seconds.
-const duration = 15 * time.Second
+// The reloads received meanwhile are applied. It returns the error from the context
+// if the context is cancelled before the duration elapses, otherwise nil.
+func (s *settings) wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case reload := <-s.opts.Reloads:
+			s.apply(reload)
+		case <-timer.C:
+			return nil
+		}
+	}
+}
+
+// metrics returns the recorder of the agent activity.
+func (s *settings) metrics() Metrics {
+	if s.opts.Metrics == nil {
+		return nopMetrics{}
+	}
+
+	return s.opts.Metrics
+}
+
+// active returns the agent IDs that are within their windows and not paused at the given time.
+//
+// The pauses that ended are removed and logged.
+func (s *settings) active(ctx context.Context, now time.Time) []uuid.UUID {
+	for _, pause := range s.opts.Pauses.expire(now) {
+		resumed := "all"
+		if pause.ID != uuid.Nil {
+			resumed = pause.ID.String()
+		}
+
+		zerolog.Ctx(ctx).Info().Str(EventFieldName, EventResume).Str("resumed", resumed).
+			Msg("pause ended, resuming update requests")
+	}
+
+	return s.opts.Pauses.active(scheduled(s.ids, s.opts.Schedules, now))
+}
+
+// next returns the delay before the next update request.
+//
+// If a phase is configured, the update request is aligned to the next slot of the agent,
+// otherwise it is sent after the given delay.
+func (s *settings) next(delay time.Duration) time.Duration {
+	if s.opts.Phase > 0 {
+		return untilSlot(time.Now(), s.opts.Interval, s.opts.Phase)
+	}
+
+	return delay
+}
 
 // Agent sends update requests to the given state service client.
 // It continuously sends update requests until the context is cancelled.
-// Returns an error if sending the update request fails.
+// Failed attempts are retried according to the backoff policy from the options.
+//
+// When the context is cancelled, the current stream is closed gracefully within
+// the drain timeout and nil is returned, so that a requested stop is not reported
+// as a failure.
+//
+// The IDs are taken from the context. They and the options are replaced by the
+// reloads received from the options while the agent is running.
 //
 // Parameters:
 // - ctx: The context.Context to use for the gRPC call.
 // - stateServiceClient: The client for the state service.
+// - opts: The settings of the agent loop.
 //
 // Returns:
-// - error: An error if sending the update request fails.
+// - error: Always nil, the agent runs until the context is cancelled.
 func Agent(
 	ctx context.Context,
 	stateServiceClient vakeel_way.StateServiceClient,
+	opts Options,
 ) error {
+	// The settings are replaced on reload.
+	current := &settings{ids: ctxid.IDs(ctx), opts: opts}
+	current.opts.Status.configured(current.ids, current.opts.Labels)
+
 	// Loop until the context is cancelled.
 	for {
 		select {
 		case <-ctx.Done():
-			// If the context is cancelled, return the error from the context.
-			return ctx.Err()
+			// If the context is cancelled, the agent has been asked to stop,
+			// unless it is restarted to reconnect with a new configuration.
+			if !errors.Is(context.Cause(ctx), ErrReconnect) {
+				current.opts.Status.stopped()
+				zerolog.Ctx(ctx).Info().Str(EventFieldName, EventStopped).Msg("agent stopped")
+			}
+
+			return nil
 		default:
+			// The stream is detached from the cancellation of the agent context, so that
+			// it can still be closed gracefully when the agent is asked to stop.
+			// It is cancelled explicitly once it has been closed or the drain timeout expires.
+			streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+			// Once the agent is asked to stop, give the stream the drain timeout to close, also
+			// while it is still being opened, so that a server that does not answer cannot hold up the stop.
+			stopDrain := context.AfterFunc(ctx, func() { time.AfterFunc(current.opts.DrainTimeout, cancel) })
+
+			// Trace the lifecycle of the stream, the update requests are its children.
+			streamCtx, span := current.tracer().Start(streamCtx, spanStream, trace.WithAttributes(agentIDs(current.ids)))
+
 			// Create a client stream to send updates to the server.
 			// This method establishes a connection with the server and returns a client stream.
 			// If the connection fails, an error is returned.
-			updateClient, err := stateServiceClient.Update(ctx)
+			updateClient, err := stateServiceClient.Update(streamCtx)
 			if err != nil {
-				// Log the error and sleep for a duration before continuing.
-				logError(ctx, err, "failed to create client stream")
-				time.Sleep(duration)
+				stopDrain()
+				cancel()
+				current.metrics().SendFailed(err)
+				current.opts.Status.failed(err)
+				recordError(span, err)
+				span.End()
+
+				// Log the error and wait before the next attempt.
+				logError(ctx, err, EventConnectFailed, "failed to create client stream")
+				retry(ctx, current)
 
 				continue
 			}
 
+			// Remember when the stream was opened to tell a healthy stream from a flapping one.
+			openedAt := time.Now()
+			current.metrics().StreamOpened()
+			current.opts.Status.connected()
+
 			// Send an update request to the server.
 			// This function sends an update request to the server using the client stream.
 			// If sending the update request fails, an error is returned.
-			if err := stream(ctx, updateClient); err != nil {
+			if err := stream(trace.ContextWithSpan(ctx, span), updateClient, current); err != nil {
 				// Log the error and continue.
-				logError(ctx, err, "failed to send update request")
+				logError(ctx, err, EventSendFailed, "failed to send update request")
+				recordError(span, err)
 			}
 
 			// Close the update stream to free resources.
 			// This method closes the client stream and waits for the response from the server.
-			// If the response is not received, an error is returned.
-			if _, err := updateClient.CloseAndRecv(); err != nil {
+			// If the response is not received within the drain timeout, the stream is cancelled.
+			if err := drain(updateClient, cancel, current.opts.DrainTimeout); err != nil {
 				// Log the error and continue.
-				logError(ctx, err, "failed to close update stream")
+				logError(ctx, err, EventCloseFailed, "failed to close update stream")
+				recordError(span, err)
+			}
+
+			stopDrain()
+			current.metrics().StreamClosed()
+			span.End()
+
+			// Start the backoff over if the stream was healthy.
+			current.opts.Backoff.Observe(time.Since(openedAt))
+
+			// The stream was closed because the agent has been asked to stop, there is nothing to retry.
+			if ctx.Err() != nil {
+				continue
 			}
+
+			// Wait before reconnecting.
+			retry(ctx, current)
 		}
 	}
 }
 
-// logError logs the error with the given message.
+// drain closes the client stream and waits for the response from the server.
+//
+// The stream is cancelled if the server does not respond within the timeout,
+// which makes the pending CloseAndRecv call return.
+//
+// Parameters:
+// - client: The client stream to close.
+// - cancel: The function that cancels the context of the client stream.
+// - timeout: The maximum time to wait for the response from the server.
+//
+// Returns:
+// - error: An error if the stream could not be closed gracefully.
+func drain(
+	client vakeel_way.StateService_UpdateClient,
+	cancel context.CancelFunc,
+	timeout time.Duration,
+) error {
+	// Cancel the stream once the timeout expires and release it in any case.
+	timer := time.AfterFunc(timeout, cancel)
+	defer timer.Stop()
+	defer cancel()
+
+	_, err := client.CloseAndRecv()
+
+	return err
+}
+
+// retry waits for the retry interval plus the next backoff delay or until the
+// context is cancelled.
+//
+// The caller is expected to check the context afterwards.
+//
+// Parameters:
+// - ctx: The context.Context used for logging and cancellation.
+// - current: The settings of the agent loop that provide the delay.
+func retry(ctx context.Context, current *settings) {
+	delay := current.opts.RetryInterval + current.opts.Backoff.Next()
+
+	current.metrics().Reconnecting()
+	current.opts.Status.reconnecting(delay)
+
+	zerolog.Ctx(ctx).Debug().Str(EventFieldName, EventRetry).Dur("delay", delay).Msg("waiting before reconnecting")
+
+	// The error only reports that the context was cancelled, which the caller checks.
+	_ = current.wait(ctx, delay)
+}
+
+// logError logs the error with the given event type and message.
 //
-// It takes a context, an error, and a message as parameters.
+// It takes a context, an error, an event type and a message as parameters.
 // The function logs the error with the given message using the zerolog logger.
 // The logger is obtained from the context and the error is logged with the message.
 //
 // Parameters:
 // - ctx: The context.Context used for logging.
 // - err: The error to log.
+// - event: The event type of the message.
 // - msg: The message to log along with the error.
-func logError(ctx context.Context, err error, msg string) {
+func logError(ctx context.Context, err error, event, msg string) {
 	// Get the logger from the context.
 	logger := zerolog.Ctx(ctx)
 
 	// Log the error with the message.
-	logger.Error().Err(err).Msg(msg)
+	logger.Error().Err(err).Str(EventFieldName, event).Msg(msg)
 }
 
 // stream sends an update request to the server at regular intervals.
 //
-// It takes a context and a client for the update service as parameters.
-// The function sends an update request to the server with the ID extracted from the context.
-// It also logs a message indicating that an update request is being sent.
+// It takes a context, a client for the update service and the current settings of the agent loop as parameters.
+// The function sends an update request to the server with the IDs from the settings.
+// If a phase is configured, every update request is aligned to the next slot of the agent.
+// A reload is applied to the open stream and the update request with the new IDs is sent right away.
+// The IDs outside their windows and the paused IDs are left out of the update requests,
+// the stream is kept open even if none is left.
 // The function returns an error if sending the update request fails.
 func stream(
 	ctx context.Context,
 	client vakeel_way.StateService_UpdateClient,
+	current *settings,
 ) error {
-	// Get the high and low parts of the UUID from the context.
-	// The UUID is extracted from the context using the ctxid.ID function.
-	high, low := uuidconv.UUID2DoubleInt(ctxid.ID(ctx))
-
-	// Send an initial update request to the server with the given UUID.
-	// The sendUpdateRequest function logs a message indicating that an update request is being sent
-	// and returns an error if sending the update request fails.
-	if err := sendUpdateRequest(ctx, client, high, low); err != nil {
-		return err
-	}
-
-	// Create a ticker to send update requests at regular intervals.
-	// The duration between update requests is set to 15 seconds.
-	ticker := time.NewTicker(duration)
-	defer ticker.Stop()
+	// Send the initial update request right away or in the aligned slot of this agent,
+	// so that agents restarted at the same moment spread their update requests over the interval.
+	timer := time.NewTimer(current.next(0))
+	defer timer.Stop()
 
 	// Loop until the context is cancelled.
 	for {
@@ -122,39 +344,87 @@ func stream(
 		case <-ctx.Done():
 			return nil
 
-		// If the ticker fires, send an update request to the server with the given UUID.
-		case <-ticker.C:
+		// If the settings are reloaded, report the new IDs without waiting for the next interval.
+		case reload := <-current.opts.Reloads:
+			current.apply(reload)
+			timer.Reset(current.next(0))
+
+		// If the timer fires, send an update request to the server with the current UUIDs.
+		case <-timer.C:
+			// Leave the IDs outside their windows and the paused IDs out,
+			// the pauses that ended are resumed on their own.
+			ids := current.active(ctx, time.Now())
+
+			// Keep the stream open but skip the update request while no ID is active.
+			if len(ids) == 0 {
+				zerolog.Ctx(ctx).Debug().Msg("no agent ID is scheduled or all of them are paused")
+				timer.Reset(current.next(current.opts.Interval))
+
+				continue
+			}
+
 			// The sendUpdateRequest function logs a message indicating that an update request is being sent
 			// and returns an error if sending the update request fails.
-			if err := sendUpdateRequest(ctx, client, high, low); err != nil {
+			if err := sendUpdateRequest(ctx, current.tracer(), client, ids, current.opts.Labels); err != nil {
+				current.metrics().SendFailed(err)
+				current.opts.Status.failed(err)
+
 				return err
 			}
+
+			current.metrics().HeartbeatSent()
+			current.opts.Status.sent()
+
+			timer.Reset(current.next(current.opts.Interval))
 		}
 	}
 }
 
-// sendUpdateRequest sends an update request to the server with the given UUID.
-// It takes a context, a client for the server's update service, and the high and low parts of the UUID.
-// The function logs a message indicating that an update request is being sent
+// sendUpdateRequest sends an update request to the server with the given UUIDs.
+// It takes a context, a tracer, a client for the server's update service, the UUIDs and their labels.
+// The function logs a message indicating that an update request is being sent, traces it as a span
 // and returns an error if sending the update request fails.
 func sendUpdateRequest(
 	ctx context.Context,
+	tracer trace.Tracer,
 	client vakeel_way.StateService_UpdateClient,
-	high int64,
-	low int64,
+	ids []uuid.UUID,
+	labels map[uuid.UUID]string,
 ) error {
-	// Create an update request with the UUID extracted from the context.
+	// Trace the update request as a child of the stream span from the context.
+	ctx, span := tracer.Start(ctx, spanSendUpdateRequest, trace.WithAttributes(agentIDs(ids)))
+	defer span.End()
+
+	// Create an update request with all the UUIDs.
+	// The names are used to log the UUIDs together with their labels.
 	updateRequest := &vakeel_way.UpdateRequest{
-		Ids: []*apiv1.UUID{
-			{High: high, Low: low},
-		},
+		Ids: make([]*apiv1.UUID, 0, len(ids)),
+	}
+	names := make([]string, 0, len(ids))
+
+	for _, id := range ids {
+		high, low := uuidconv.UUID2DoubleInt(id)
+		updateRequest.Ids = append(updateRequest.Ids, &apiv1.UUID{High: high, Low: low})
+		names = append(names, displayName(id, labels))
 	}
 
 	// Log a message indicating that an update request is being sent.
-	// The message includes the UUID that is being sent.
-	zerolog.Ctx(ctx).Info().Msgf("sending update request: %s", uuidconv.DoubleInt2UUID(high, low))
+	// The message includes the UUIDs that are being sent.
+	zerolog.Ctx(ctx).Info().Str(EventFieldName, EventUpdate).Msgf("sending update request: %s", strings.Join(names, ", "))
 
 	// Send the update request to the server.
 	// The function returns an error if sending the update request fails.
-	return client.Send(updateRequest)
+	err := client.Send(updateRequest)
+	recordError(span, err)
+
+	return err
+}
+
+// displayName returns the UUID followed by its label in parentheses, if it has one.
+func displayName(id uuid.UUID, labels map[uuid.UUID]string) string {
+	if label, ok := labels[id]; ok {
+		return id.String() + " (" + label + ")"
+	}
+
+	return id.String()
 }
@@ -0,0 +1,87 @@
+package app
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+
+	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+func TestAgentStopWhileConnecting(t *testing.T) {
+	t.Parallel()
+
+	// The server accepts the connection but never answers, so the stream is never opened.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = listener.Close() })
+
+	go func() {
+		var conns []net.Conn
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				break
+			}
+
+			conns = append(conns, conn)
+		}
+
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+	}()
+
+	conn, err := grpc.NewClient(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	backoff, err := NewBackoff(time.Second, time.Second, 1, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(ctxid.WithIDs(context.Background(), uuid.New()))
+	done := make(chan error, 1)
+
+	go func() {
+		done <- Agent(ctx, vakeel_way.NewStateServiceClient(conn), Options{
+			Interval:     time.Minute,
+			Backoff:      backoff,
+			DrainTimeout: 100 * time.Millisecond,
+		})
+	}()
+
+	// Give the agent time to start opening the stream before it is asked to stop.
+	time.Sleep(100 * time.Millisecond)
+
+	stopped := time.Now()
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("Agent() error = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Agent() did not stop within the drain timeout")
+	}
+
+	if elapsed := time.Since(stopped); elapsed > time.Second {
+		t.Fatalf("Agent() stopped after %v, want about the drain timeout", elapsed)
+	}
+}
@@ -0,0 +1,116 @@
+package app
+
+import (
+	"errors"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// errBackoffInitial is the error returned when the initial backoff delay is not positive.
+var errBackoffInitial = errors.New("backoff initial delay must be positive")
+
+// errBackoffMax is the error returned when the maximum backoff delay is less than the initial delay.
+var errBackoffMax = errors.New("backoff max delay must not be less than the initial delay")
+
+// errBackoffMultiplier is the error returned when the backoff multiplier is less than 1.
+var errBackoffMultiplier = errors.New("backoff multiplier must be at least 1")
+
+// Backoff is the reconnection policy of the agent.
+//
+// The delay grows exponentially with every failed attempt and is capped by the
+// maximum delay. The actual wait is chosen uniformly between zero and the capped
+// delay (full jitter), so agents that lost the server at the same moment do not
+// reconnect at the same moment.
+//
+// Backoff is not safe for concurrent use.
+type Backoff struct {
+	// initial is the delay ceiling of the first attempt.
+	initial time.Duration
+	// maxDelay is the upper bound of the delay ceiling.
+	maxDelay time.Duration
+	// multiplier is the growth factor of the delay ceiling between attempts.
+	multiplier float64
+	// resetAfter is the stream lifetime after which the stream is considered healthy.
+	resetAfter time.Duration
+	// attempt is the number of consecutive failed attempts.
+	attempt int
+}
+
+// NewBackoff creates a new Backoff policy.
+//
+// Parameters:
+// - initial: The delay ceiling of the first attempt.
+// - maxDelay: The upper bound of the delay ceiling.
+// - multiplier: The growth factor of the delay ceiling between attempts.
+// - resetAfter: The stream lifetime after which the policy starts over.
+//
+// Returns:
+// - *Backoff: A pointer to the Backoff instance.
+// - error: An error if the parameters are invalid.
+func NewBackoff(
+	initial time.Duration,
+	maxDelay time.Duration,
+	multiplier float64,
+	resetAfter time.Duration,
+) (*Backoff, error) {
+	if initial <= 0 {
+		return nil, errBackoffInitial
+	}
+
+	if maxDelay < initial {
+		return nil, errBackoffMax
+	}
+
+	if multiplier < 1 {
+		return nil, errBackoffMultiplier
+	}
+
+	return &Backoff{
+		initial:    initial,
+		maxDelay:   maxDelay,
+		multiplier: multiplier,
+		resetAfter: resetAfter,
+	}, nil
+}
+
+// Next returns the delay to wait before the next attempt and advances the policy.
+//
+// The delay is a random value between zero and min(max, initial*multiplier^attempt).
+func (b *Backoff) Next() time.Duration {
+	ceiling := b.Ceiling()
+
+	// Advance the attempt counter, but stop once the ceiling has reached the maximum
+	// delay to keep the exponent from growing without bound.
+	if ceiling < b.maxDelay {
+		b.attempt++
+	}
+
+	//nolint:gosec // The jitter does not need a cryptographically secure source.
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// Ceiling returns the upper bound of the delay for the current attempt.
+func (b *Backoff) Ceiling() time.Duration {
+	ceiling := float64(b.initial) * math.Pow(b.multiplier, float64(b.attempt))
+	if ceiling >= float64(b.maxDelay) {
+		return b.maxDelay
+	}
+
+	return time.Duration(ceiling)
+}
+
+// Reset starts the policy over from the initial delay.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// Observe resets the policy if the stream lived long enough to be considered healthy.
+//
+// Parameters:
+// - lifetime: The duration the stream was open.
+func (b *Backoff) Observe(lifetime time.Duration) {
+	if lifetime >= b.resetAfter {
+		b.Reset()
+	}
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNewBackoffInvalid(t *testing.T) {
+	t.Parallel()
+
+	if _, err := NewBackoff(0, time.Minute, 2, time.Minute); !errors.Is(err, errBackoffInitial) {
+		t.Fatalf("NewBackoff() error = %v, want %v", err, errBackoffInitial)
+	}
+
+	if _, err := NewBackoff(time.Minute, time.Second, 2, time.Minute); !errors.Is(err, errBackoffMax) {
+		t.Fatalf("NewBackoff() error = %v, want %v", err, errBackoffMax)
+	}
+
+	if _, err := NewBackoff(time.Second, time.Minute, 0.5, time.Minute); !errors.Is(err, errBackoffMultiplier) {
+		t.Fatalf("NewBackoff() error = %v, want %v", err, errBackoffMultiplier)
+	}
+}
+
+func TestBackoffNext(t *testing.T) {
+	t.Parallel()
+
+	backoff, err := NewBackoff(time.Second, 10*time.Second, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The ceiling doubles with every attempt until it is capped, the delays are jittered below it.
+	for _, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second} {
+		if got := backoff.Ceiling(); got != want {
+			t.Fatalf("Ceiling() = %v, want %v", got, want)
+		}
+
+		if got := backoff.Next(); got < 0 || got > want {
+			t.Fatalf("Next() = %v, want within [0, %v]", got, want)
+		}
+	}
+
+	if got := backoff.Ceiling(); got != 10*time.Second {
+		t.Fatalf("Ceiling() after the cap = %v, want %v", got, 10*time.Second)
+	}
+}
+
+func TestBackoffObserve(t *testing.T) {
+	t.Parallel()
+
+	backoff, err := NewBackoff(time.Second, time.Minute, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	backoff.Next()
+	backoff.Next()
+
+	// A stream that closed early keeps the policy going, a healthy one starts it over.
+	backoff.Observe(59 * time.Second)
+
+	if got := backoff.Ceiling(); got != 4*time.Second {
+		t.Fatalf("Ceiling() after a short stream = %v, want %v", got, 4*time.Second)
+	}
+
+	backoff.Observe(time.Minute)
+
+	if got := backoff.Ceiling(); got != time.Second {
+		t.Fatalf("Ceiling() after a healthy stream = %v, want %v", got, time.Second)
+	}
+}
@@ -0,0 +1,131 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
+)
+
+// ErrCheckSkipped is the error returned by a check that does not apply, e.g. the TLS handshake
+// of a plaintext connection. The checks that follow it are still run.
+var ErrCheckSkipped = errors.New("skipped")
+
+// CheckStatus is the outcome of a check.
+type CheckStatus string
+
+// Outcomes of a check.
+const (
+	// CheckPassed is the outcome of a check that succeeded.
+	CheckPassed CheckStatus = "pass"
+	// CheckFailed is the outcome of a check that failed.
+	CheckFailed CheckStatus = "fail"
+	// CheckSkipped is the outcome of a check that does not apply or depends on a check that failed.
+	CheckSkipped CheckStatus = "skip"
+)
+
+// Check is a step of the diagnosis of the agent.
+type Check struct {
+	// Name is the short name of the check, e.g. "dns".
+	Name string
+	// Hint is the remediation shown when the check fails.
+	Hint string
+	// Run runs the check and returns what it found, or the error if it failed.
+	// It returns an error wrapping ErrCheckSkipped if the check does not apply.
+	Run func(ctx context.Context) (string, error)
+}
+
+// CheckResult is the outcome of a check.
+type CheckResult struct {
+	// Name is the short name of the check.
+	Name string
+	// Status is the outcome of the check.
+	Status CheckStatus
+	// Detail is what the check found, the error if it failed or why it was skipped.
+	Detail string
+	// Hint is the remediation of a failed check, empty otherwise.
+	Hint string
+	// Duration is the run time of the check.
+	Duration time.Duration
+}
+
+// Diagnose runs the chains of checks one after another.
+//
+// The checks of a chain depend on each other, so once a check fails the rest of its chain is skipped.
+// Every check is given the timeout.
+//
+// Parameters:
+// - ctx: The context of the checks.
+// - timeout: The maximum run time of a check.
+// - chains: The chains of checks.
+//
+// Returns:
+// - []CheckResult: The outcomes of the checks in the order they were given.
+func Diagnose(ctx context.Context, timeout time.Duration, chains ...[]Check) []CheckResult {
+	var results []CheckResult
+
+	for _, chain := range chains {
+		failed := ""
+
+		for _, check := range chain {
+			if failed != "" {
+				results = append(results, CheckResult{
+					Name:   check.Name,
+					Status: CheckSkipped,
+					Detail: "skipped, " + failed + " failed",
+				})
+
+				continue
+			}
+
+			result := runCheck(ctx, timeout, check)
+			if result.Status == CheckFailed {
+				failed = check.Name
+			}
+
+			results = append(results, result)
+		}
+	}
+
+	return results
+}
+
+// runCheck runs the check with the timeout.
+func runCheck(ctx context.Context, timeout time.Duration, check Check) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	started := time.Now()
+	detail, err := check.Run(ctx)
+	result := CheckResult{Name: check.Name, Status: CheckPassed, Detail: detail, Duration: time.Since(started)}
+
+	switch {
+	case errors.Is(err, ErrCheckSkipped):
+		result.Status, result.Detail = CheckSkipped, err.Error()
+	case err != nil:
+		result.Status, result.Detail, result.Hint = CheckFailed, err.Error(), check.Hint
+	}
+
+	return result
+}
+
+// ProbeUpdate opens an update stream and closes it without sending any update request,
+// so that the server accepts or rejects the agent without being told that the agent IDs are up.
+//
+// Parameters:
+// - ctx: The context of the stream.
+// - client: The client of the server's update service.
+//
+// Returns:
+// - error: An error if the stream cannot be opened or the server rejects it.
+func ProbeUpdate(ctx context.Context, client vakeel_way.StateServiceClient) error {
+	stream, err := client.Update(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = stream.CloseAndRecv()
+
+	return err
+}
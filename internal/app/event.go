@@ -0,0 +1,37 @@
+package app
+
+// EventFieldName is the name of the log field with the event type of a message.
+//
+// The event types allow to filter the logs by what happened, e.g.
+// "journalctl VAKEEL_EVENT=connect_failed".
+const EventFieldName = "event"
+
+// Event types of the agent.
+const (
+	// EventUpdate is logged when an update request is sent.
+	EventUpdate = "update"
+	// EventConnectFailed is logged when the stream cannot be opened.
+	EventConnectFailed = "connect_failed"
+	// EventSendFailed is logged when an update request cannot be sent.
+	EventSendFailed = "send_failed"
+	// EventCloseFailed is logged when the stream cannot be closed gracefully.
+	EventCloseFailed = "close_failed"
+	// EventRetry is logged when the agent waits before reconnecting.
+	EventRetry = "retry"
+	// EventStopped is logged when the agent stops.
+	EventStopped = "stopped"
+	// EventReload is logged when the configuration is reloaded.
+	EventReload = "reload"
+	// EventReloadFailed is logged when the configuration cannot be reloaded.
+	EventReloadFailed = "reload_failed"
+	// EventReconnect is logged when the agent reconnects to apply a new configuration.
+	EventReconnect = "reconnect"
+	// EventPause is logged when the update requests are paused.
+	EventPause = "pause"
+	// EventResume is logged when the update requests are resumed.
+	EventResume = "resume"
+	// EventJobSucceeded is logged when a job run by exec exits successfully.
+	EventJobSucceeded = "job_succeeded"
+	// EventJobFailed is logged when a job run by exec fails, times out or cannot be run.
+	EventJobFailed = "job_failed"
+)
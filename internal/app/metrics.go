@@ -0,0 +1,24 @@
+package app
+
+// Metrics records the activity of the agent, e.g. to expose it to Prometheus.
+type Metrics interface {
+	// StreamOpened is called when a stream to the server has been opened.
+	StreamOpened()
+	// StreamClosed is called when the stream has been closed.
+	StreamClosed()
+	// Reconnecting is called when the agent waits before reconnecting to the server.
+	Reconnecting()
+	// HeartbeatSent is called when an update request has been sent.
+	HeartbeatSent()
+	// SendFailed is called when a stream cannot be opened or an update request cannot be sent.
+	SendFailed(err error)
+}
+
+// nopMetrics is the Metrics implementation that records nothing.
+type nopMetrics struct{}
+
+func (nopMetrics) StreamOpened()    {}
+func (nopMetrics) StreamClosed()    {}
+func (nopMetrics) Reconnecting()    {}
+func (nopMetrics) HeartbeatSent()   {}
+func (nopMetrics) SendFailed(error) {}
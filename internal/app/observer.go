@@ -0,0 +1,23 @@
+package app
+
+import "time"
+
+// StateChange is a change of the connection state of the agent.
+type StateChange struct {
+	// From is the previous connection state.
+	From State
+	// To is the new connection state.
+	To State
+	// At is the time the new state was entered.
+	At time.Time
+	// Err is the error that made the agent leave the previous state, nil if there was none.
+	Err error
+}
+
+// Observer is notified of the changes of the connection state of the agent,
+// e.g. to run the hooks of the transitions.
+type Observer interface {
+	// StateChanged is called by the agent loop when the connection state changes.
+	// It must not block the agent loop.
+	StateChanged(change StateChange)
+}
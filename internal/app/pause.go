@@ -0,0 +1,157 @@
+package app
+
+import (
+	"bytes"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Pause is a pause of the update requests of an agent ID until a deadline, e.g. during maintenance.
+type Pause struct {
+	// ID is the paused agent ID, uuid.Nil pauses all the agent IDs.
+	ID uuid.UUID
+	// Until is the time the update requests are sent again.
+	Until time.Time
+}
+
+// Pauses holds the pauses of the update requests of the agent.
+//
+// The agent leaves the paused IDs out of the update requests and keeps the stream open.
+// A pause ends on its own once its deadline passes. A nil Pauses pauses nothing.
+type Pauses struct {
+	// mu guards the pauses.
+	mu sync.Mutex
+	// until maps the paused agent IDs to the end of their pause, uuid.Nil pauses all of them.
+	until map[uuid.UUID]time.Time
+}
+
+// NewPauses creates the pauses of the agent, e.g. restored from the state file.
+//
+// Parameters:
+// - pauses: The pauses in effect.
+//
+// Returns:
+// - *Pauses: A pointer to the Pauses instance.
+func NewPauses(pauses []Pause) *Pauses {
+	p := &Pauses{until: make(map[uuid.UUID]time.Time, len(pauses))}
+
+	for _, pause := range pauses {
+		p.until[pause.ID] = pause.Until
+	}
+
+	return p
+}
+
+// Pause pauses the update requests of the given agent IDs, or of all of them if none is given,
+// until the given time. A previous pause of the same IDs is replaced.
+func (p *Pauses) Pause(ids []uuid.UUID, until time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(ids) == 0 {
+		// The pause of all the IDs replaces the pauses of the single IDs.
+		clear(p.until)
+		p.until[uuid.Nil] = until
+
+		return
+	}
+
+	for _, id := range ids {
+		p.until[id] = until
+	}
+}
+
+// Resume ends the pauses of the given agent IDs, or all the pauses if none is given.
+//
+// If all the IDs are paused, the pause is kept for the configured IDs other than the given ones.
+func (p *Pauses) Resume(ids, configured []uuid.UUID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(ids) == 0 {
+		clear(p.until)
+
+		return
+	}
+
+	// Split the pause of all the IDs into the pauses of the IDs that stay paused.
+	if until, ok := p.until[uuid.Nil]; ok {
+		delete(p.until, uuid.Nil)
+
+		for _, id := range configured {
+			if current, ok := p.until[id]; !ok || current.Before(until) {
+				p.until[id] = until
+			}
+		}
+	}
+
+	for _, id := range ids {
+		delete(p.until, id)
+	}
+}
+
+// List returns the pauses in effect, the pause of all the IDs first and then ordered by ID.
+func (p *Pauses) List() []Pause {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pauses := make([]Pause, 0, len(p.until))
+	for id, until := range p.until {
+		pauses = append(pauses, Pause{ID: id, Until: until})
+	}
+
+	// The nil UUID sorts first.
+	slices.SortFunc(pauses, func(a, b Pause) int {
+		return bytes.Compare(a.ID[:], b.ID[:])
+	})
+
+	return pauses
+}
+
+// expire removes the pauses that ended at the given time and returns them.
+func (p *Pauses) expire(now time.Time) []Pause {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var expired []Pause
+
+	for id, until := range p.until {
+		if !now.Before(until) {
+			expired = append(expired, Pause{ID: id, Until: until})
+			delete(p.until, id)
+		}
+	}
+
+	return expired
+}
+
+// active returns the given agent IDs that are not paused.
+func (p *Pauses) active(ids []uuid.UUID) []uuid.UUID {
+	if p == nil {
+		return ids
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.until[uuid.Nil]; ok {
+		return nil
+	}
+
+	return slices.DeleteFunc(slices.Clone(ids), func(id uuid.UUID) bool {
+		_, ok := p.until[id]
+
+		return ok
+	})
+}
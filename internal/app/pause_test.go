@@ -0,0 +1,51 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPauses(t *testing.T) {
+	t.Parallel()
+
+	first := uuid.MustParse("224f8a59-6705-4f3e-b7de-177757932aad")
+	second := uuid.MustParse("324f8a59-6705-4f3e-b7de-177757932aad")
+	third := uuid.MustParse("424f8a59-6705-4f3e-b7de-177757932aad")
+	configured := []uuid.UUID{first, second, third}
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	soon, later := now.Add(time.Hour), now.Add(2*time.Hour)
+
+	// A pause of all IDs replaces the pauses of single IDs, a later pause of a single ID is kept next to it.
+	pauses := NewPauses([]Pause{{ID: first, Until: later}})
+	pauses.Pause(nil, soon)
+	pauses.Pause([]uuid.UUID{third}, later)
+
+	if got := pauses.active(configured); len(got) != 0 {
+		t.Fatalf("active() during a pause of all IDs = %v, want none", got)
+	}
+
+	// Resuming one ID of a pause of all IDs keeps the others and the longer pause paused.
+	pauses.Resume([]uuid.UUID{first}, configured)
+
+	want := []Pause{{ID: second, Until: soon}, {ID: third, Until: later}}
+	if got := pauses.List(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("List() = %v, want %v", got, want)
+	}
+
+	if got, want := pauses.active(configured), []uuid.UUID{first}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("active() = %v, want %v", got, want)
+	}
+
+	// A pause ends at its deadline.
+	if got, want := pauses.expire(soon), []Pause{{ID: second, Until: soon}}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expire() = %v, want %v", got, want)
+	}
+
+	if got, want := pauses.active(configured), []uuid.UUID{first, second}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("active() after the deadline = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,58 @@
+package app
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PhaseOffset returns the deterministic offset of the agent within the interval.
+//
+// The offset is derived from the agent ID, so it stays the same across restarts
+// while agents with different IDs are spread evenly over the interval.
+//
+// Parameters:
+// - id: The ID of the agent.
+// - interval: The duration between update requests.
+//
+// Returns:
+// - time.Duration: The offset in the range [0, interval).
+func PhaseOffset(id uuid.UUID, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+
+	hash := fnv.New64a()
+	_, _ = hash.Write(id[:])
+
+	return time.Duration(hash.Sum64() % uint64(interval))
+}
+
+// untilSlot returns the time left until the next slot aligned to the interval and shifted by the phase.
+//
+// Parameters:
+// - now: The current time.
+// - interval: The duration between slots.
+// - phase: The offset of the slots within the interval.
+//
+// Returns:
+// - time.Duration: The time left until the next slot.
+func untilSlot(now time.Time, interval, phase time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+
+	// Shift the time back by the phase, find the time elapsed since the last slot
+	// and return the remainder of the interval.
+	elapsed := time.Duration(now.Add(-phase).UnixNano() % int64(interval))
+	if elapsed < 0 {
+		elapsed += interval
+	}
+
+	if elapsed == 0 {
+		return 0
+	}
+
+	return interval - elapsed
+}
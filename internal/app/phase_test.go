@@ -0,0 +1,57 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPhaseOffset(t *testing.T) {
+	t.Parallel()
+
+	id := uuid.MustParse("224f8a59-6705-4f3e-b7de-177757932aad")
+	other := uuid.MustParse("324f8a59-6705-4f3e-b7de-177757932aad")
+
+	got := PhaseOffset(id, time.Minute)
+	if got < 0 || got >= time.Minute {
+		t.Fatalf("PhaseOffset() = %v, want within [0, %v)", got, time.Minute)
+	}
+
+	// The offset is derived from the ID only, so it survives restarts.
+	if again := PhaseOffset(id, time.Minute); again != got {
+		t.Fatalf("PhaseOffset() = %v, then %v", got, again)
+	}
+
+	if PhaseOffset(other, time.Hour) == PhaseOffset(id, time.Hour) {
+		t.Fatal("PhaseOffset() is the same for different IDs")
+	}
+
+	if got := PhaseOffset(id, 0); got != 0 {
+		t.Fatalf("PhaseOffset() without interval = %v, want 0", got)
+	}
+}
+
+func TestUntilSlot(t *testing.T) {
+	t.Parallel()
+
+	base := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		now   time.Time
+		phase time.Duration
+		want  time.Duration
+	}{
+		{now: base, want: 0},
+		{now: base.Add(20 * time.Second), want: 40 * time.Second},
+		{now: base, phase: 15 * time.Second, want: 15 * time.Second},
+		{now: base.Add(20 * time.Second), phase: 15 * time.Second, want: 55 * time.Second},
+		{now: time.Unix(-10, 0), want: 10 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := untilSlot(tt.now, time.Minute, tt.phase); got != tt.want {
+			t.Errorf("untilSlot(%v, %v) = %v, want %v", tt.now, tt.phase, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
+)
+
+// Ping sends a single update request with the agent IDs on a new stream and closes the stream,
+// waiting for the server to acknowledge it. It reports the agent IDs once, without running the agent.
+//
+// Parameters:
+// - ctx: The context of the stream, its deadline limits the wait for the server.
+// - client: The client of the server's update service.
+// - ids: The agent IDs to report.
+// - labels: The human-readable names of the agent IDs used in logs.
+//
+// Returns:
+// - error: An error if the stream cannot be opened or the server does not acknowledge the update request.
+func Ping(ctx context.Context, client vakeel_way.StateServiceClient, ids []uuid.UUID, labels map[uuid.UUID]string) error {
+	stream, err := client.Update(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to open the update stream: %w", err)
+	}
+
+	// A send fails with io.EOF if the server closed the stream, the reason is returned by CloseAndRecv.
+	if err := sendUpdateRequest(ctx, nopTracer(), stream, ids, labels); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to send the update request: %w", err)
+	}
+
+	if _, err := stream.CloseAndRecv(); err != nil {
+		return fmt.Errorf("the server did not acknowledge the update request: %w", err)
+	}
+
+	return nil
+}
+
+// Heartbeat reports the agent IDs to the server every interval until the context is cancelled,
+// e.g. while a long job is running. The failed update requests are logged and retried on the next tick.
+//
+// Parameters:
+// - ctx: The context that stops the heartbeats, it provides the logger.
+// - client: The client of the server's update service.
+// - interval: The duration between two update requests, the first one is sent right away.
+// - timeout: The maximum time to wait for the server to acknowledge an update request.
+// - ids: The agent IDs to report.
+// - labels: The human-readable names of the agent IDs used in logs.
+func Heartbeat(
+	ctx context.Context,
+	client vakeel_way.StateServiceClient,
+	interval, timeout time.Duration,
+	ids []uuid.UUID,
+	labels map[uuid.UUID]string,
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pingCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := Ping(pingCtx, client, ids, labels)
+
+		cancel()
+
+		if err != nil && ctx.Err() == nil {
+			zerolog.Ctx(ctx).Warn().Err(err).Str(EventFieldName, EventSendFailed).Msg("failed to send heartbeat")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
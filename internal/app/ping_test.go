@@ -0,0 +1,113 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
+)
+
+// testServer counts the update requests it acknowledges.
+type testServer struct {
+	vakeel_way.UnimplementedStateServiceServer
+
+	// requests is the number of the update requests received.
+	requests atomic.Int64
+}
+
+// Update acknowledges the update requests of the stream once the client closes it.
+func (s *testServer) Update(stream vakeel_way.StateService_UpdateServer) error {
+	for {
+		if _, err := stream.Recv(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return stream.SendAndClose(&vakeel_way.UpdateResponse{})
+			}
+
+			return err
+		}
+
+		s.requests.Add(1)
+	}
+}
+
+// newTestClient starts the server in memory and returns a client connected to it.
+func newTestClient(t *testing.T, server vakeel_way.StateServiceServer) vakeel_way.StateServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+
+	grpcServer := grpc.NewServer()
+	vakeel_way.RegisterStateServiceServer(grpcServer, server)
+
+	go func() { _ = grpcServer.Serve(listener) }()
+
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return vakeel_way.NewStateServiceClient(conn)
+}
+
+func TestHeartbeat(t *testing.T) {
+	t.Parallel()
+
+	server := &testServer{}
+	client := newTestClient(t, server)
+	ids := []uuid.UUID{uuid.MustParse("224f8a59-6705-4f3e-b7de-177757932aad")}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		Heartbeat(ctx, client, 20*time.Millisecond, time.Second, ids, nil)
+	}()
+
+	// The first update request is sent right away, the others every interval.
+	deadline := time.Now().Add(5 * time.Second)
+	for server.requests.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d update requests were acknowledged, want 3", server.requests.Load())
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Heartbeat() did not return once the context was cancelled")
+	}
+
+	// No update request is sent after the heartbeats were stopped.
+	sent := server.requests.Load()
+
+	time.Sleep(100 * time.Millisecond)
+
+	if got := server.requests.Load(); got != sent {
+		t.Fatalf("%d update requests after the stop, want %d", got, sent)
+	}
+}
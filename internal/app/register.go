@@ -5,12 +5,12 @@ import (
 )
 
 type RegisterUseCase interface {
-	Register() error
+	Register(ctx context.Context) error
 }
 
 func AgentRegister(
-	_ context.Context,
+	ctx context.Context,
 	register RegisterUseCase,
 ) error {
-	return register.Register()
+	return register.Register(ctx)
 }
@@ -0,0 +1,29 @@
+package app
+
+import (
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schedule is the recurring window in which an agent ID sends update requests,
+// e.g. the business hours of a device that is only expected to be up during them.
+type Schedule interface {
+	// Active reports whether the time is within the window.
+	Active(t time.Time) bool
+}
+
+// scheduled returns the agent IDs that are within their windows at the given time.
+// The IDs without a schedule are always within their windows.
+func scheduled(ids []uuid.UUID, schedules map[uuid.UUID]Schedule, now time.Time) []uuid.UUID {
+	if len(schedules) == 0 {
+		return ids
+	}
+
+	return slices.DeleteFunc(slices.Clone(ids), func(id uuid.UUID) bool {
+		schedule, ok := schedules[id]
+
+		return ok && !schedule.Active(now)
+	})
+}
@@ -0,0 +1,184 @@
+package app
+
+import (
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// State is the connection state of the agent.
+type State string
+
+// Connection states of the agent.
+const (
+	// StateConnecting is the state of the agent before the first stream has been opened.
+	StateConnecting State = "connecting"
+	// StateConnected is the state of the agent while a stream to the server is open.
+	StateConnected State = "connected"
+	// StateReconnecting is the state of the agent while it waits before reconnecting to the server.
+	StateReconnecting State = "reconnecting"
+	// StateStopped is the state of the agent once it has been stopped.
+	StateStopped State = "stopped"
+)
+
+// Status is the shared state of a running agent.
+//
+// It is updated by the agent loop and read by the control socket. The observers are notified
+// of the changes of the connection state. A nil Status records nothing.
+type Status struct {
+	// observers are notified of the changes of the connection state.
+	observers []Observer
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// state is the current connection state.
+	state State
+	// since is the time the current state was entered.
+	since time.Time
+	// lastSend is the time of the last successful update request, zero before the first one.
+	lastSend time.Time
+	// lastError is the last error of a stream open or an update request, nil if there was none.
+	lastError error
+	// lastErrorAt is the time of the last error.
+	lastErrorAt time.Time
+	// backoff is the delay before the next reconnection attempt while reconnecting.
+	backoff time.Duration
+	// ids are the agent IDs sent in every update request.
+	ids []uuid.UUID
+	// labels are the human-readable names of the agent IDs.
+	labels map[uuid.UUID]string
+}
+
+// Snapshot is a copy of the state of the agent at a point in time.
+type Snapshot struct {
+	// State is the connection state.
+	State State
+	// Since is the time the state was entered.
+	Since time.Time
+	// LastSend is the time of the last successful update request, zero before the first one.
+	LastSend time.Time
+	// LastError is the last error of a stream open or an update request, nil if there was none.
+	LastError error
+	// Backoff is the delay before the next reconnection attempt, zero unless reconnecting.
+	Backoff time.Duration
+	// IDs are the agent IDs sent in every update request.
+	IDs []uuid.UUID
+	// Labels are the human-readable names of the agent IDs.
+	Labels map[uuid.UUID]string
+}
+
+// NewStatus creates the status of an agent that has not connected yet.
+//
+// Parameters:
+// - observers: The observers notified of the changes of the connection state.
+//
+// Returns:
+// - *Status: A pointer to the Status instance.
+func NewStatus(observers ...Observer) *Status {
+	return &Status{observers: observers, state: StateConnecting, since: time.Now()}
+}
+
+// Snapshot returns a copy of the current state of the agent.
+func (s *Status) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Snapshot{
+		State:     s.state,
+		Since:     s.since,
+		LastSend:  s.lastSend,
+		LastError: s.lastError,
+		Backoff:   s.backoff,
+		IDs:       slices.Clone(s.ids),
+		Labels:    maps.Clone(s.labels),
+	}
+}
+
+// configured records the agent IDs and their labels.
+func (s *Status) configured(ids []uuid.UUID, labels map[uuid.UUID]string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.ids, s.labels = slices.Clone(ids), maps.Clone(labels)
+}
+
+// connected records that a stream to the server has been opened.
+func (s *Status) connected() {
+	s.transition(StateConnected, 0)
+}
+
+// reconnecting records that the agent waits for the given delay before reconnecting.
+func (s *Status) reconnecting(delay time.Duration) {
+	s.transition(StateReconnecting, delay)
+}
+
+// stopped records that the agent has been stopped.
+func (s *Status) stopped() {
+	s.transition(StateStopped, 0)
+}
+
+// sent records a successful update request.
+func (s *Status) sent() {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastSend = time.Now()
+}
+
+// failed records a failed stream open or update request.
+func (s *Status) failed(err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.lastError, s.lastErrorAt = err, time.Now()
+}
+
+// transition records the new connection state and the backoff delay.
+//
+// The time the state was entered is only updated and the observers are only notified if the state changes.
+func (s *Status) transition(state State, backoff time.Duration) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+
+	s.backoff = backoff
+
+	if s.state == state {
+		s.mu.Unlock()
+
+		return
+	}
+
+	change := StateChange{From: s.state, To: state, At: time.Now()}
+
+	// Only an error that occurred in the previous state made the agent leave it.
+	if s.lastError != nil && !s.lastErrorAt.Before(s.since) {
+		change.Err = s.lastError
+	}
+
+	s.state, s.since = state, change.At
+
+	s.mu.Unlock()
+
+	// The observers are notified outside of the lock, so that they can read the status.
+	for _, observer := range s.observers {
+		observer.StateChanged(change)
+	}
+}
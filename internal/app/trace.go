@@ -0,0 +1,55 @@
+package app
+
+import (
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Names of the spans of the agent.
+const (
+	// spanStream is the span of the lifecycle of a stream, from opening it to closing it.
+	spanStream = "vakeel.stream"
+	// spanSendUpdateRequest is the span of a single update request.
+	spanSendUpdateRequest = "vakeel.sendUpdateRequest"
+)
+
+// agentIDKey is the attribute with the agent IDs of a span.
+const agentIDKey = attribute.Key("vakeel.agent.id")
+
+// agentIDs returns the attribute with the agent IDs.
+func agentIDs(ids []uuid.UUID) attribute.KeyValue {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+
+	return agentIDKey.StringSlice(values)
+}
+
+// recordError records the error on the span and marks the span as failed.
+// Nothing is recorded if the error is nil.
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// tracer returns the tracer of the agent spans.
+func (s *settings) tracer() trace.Tracer {
+	if s.opts.Tracer == nil {
+		return nopTracer()
+	}
+
+	return s.opts.Tracer
+}
+
+// nopTracer returns the tracer that records nothing, used when the traces are not exported.
+func nopTracer() trace.Tracer {
+	return noop.NewTracerProvider().Tracer("")
+}
@@ -2,17 +2,29 @@ package build
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/keepalive"
 
 	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
 	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/metrics"
+	"github.com/bavix/vakeel/internal/infra/schedule"
+	"github.com/bavix/vakeel/internal/infra/telemetry"
 	"github.com/bavix/vakeel/internal/infra/templater"
+	"github.com/bavix/vakeel/internal/infra/transport"
 	"github.com/bavix/vakeel/pkg/ctxid"
 )
 
@@ -26,40 +38,572 @@ const (
 	allowWithoutStreams = true             // Allow the connection to be established without a stream.
 )
 
+// telemetryFlushTimeout is the maximum time to flush the pending telemetry when the agent stops.
+const telemetryFlushTimeout = 5 * time.Second
+
+// certWatchInterval is the interval between checks of the client certificate files.
+const certWatchInterval = 30 * time.Second
+
+// errInvalidInterval is the error returned when the interval between update requests is not positive.
+var errInvalidInterval = errors.New("interval must be positive")
+
+// errTokenPlaintext is the error returned when the auth token would be sent without TLS.
+var errTokenPlaintext = errors.New("the auth token cannot be sent over plaintext")
+
+// errInvalidRetryInterval is the error returned when the retry interval is negative.
+var errInvalidRetryInterval = errors.New("retry interval must not be negative")
+
+// errInvalidSchedule is the error returned when a schedule is not in the "<uuid>=<cron expression>" format.
+var errInvalidSchedule = errors.New("schedule must be in the <uuid>=<cron expression> format")
+
+// errUnknownScheduleID is the error returned when a schedule refers to an agent ID that is not configured.
+var errUnknownScheduleID = errors.New("schedule of an agent ID that is not configured")
+
+// errDuplicateSchedule is the error returned when an agent ID has more than one schedule.
+var errDuplicateSchedule = errors.New("duplicate schedule of agent ID")
+
+// agentSetup holds the state of the agent derived from a configuration.
+type agentSetup struct {
+	// target is the address of the server.
+	target string
+	// token is the auth token of the agent.
+	token string
+	// ids are the agent IDs sent in every update request.
+	ids []uuid.UUID
+	// options are the settings of the agent loop.
+	options app.Options
+	// backoffConfig is the configuration of the reconnection policy of the options.
+	backoffConfig config.Backoff
+	// telemetry exports the traces and the metrics of the agent, nil if the export is disabled.
+	telemetry *telemetry.Telemetry
+	// watchCertificate reports whether the client certificate is watched for rotation and expiry
+	// while connected. Only the agent runs long enough to need it, the short commands reload it on handshake.
+	watchCertificate bool
+}
+
+// connection is a client connection to the server.
+type connection struct {
+	// conn is the gRPC client connection.
+	conn *grpc.ClientConn
+	// stop stops the client certificate watcher of the connection.
+	stop context.CancelFunc
+}
+
+// close closes the connection and stops its client certificate watcher.
+func (c *connection) close() {
+	c.stop()
+	_ = c.conn.Close()
+}
+
 // AgentApp creates a gRPC client and connects to the server's update service.
 // It returns an error if the connection or the update service call fails.
 //
+// The configurations received from the reloads channel replace the current one while
+// the agent is running. The connection is kept if neither the target nor the credentials
+// changed, otherwise the agent reconnects. An invalid configuration is logged and ignored.
+// The log level is applied as well, the other settings that need a restart are logged.
+//
 // ctx: The context.Context to use for the gRPC call.
+// reloads: The configurations to apply while the agent is running, nil disables reloading.
 // Returns: An error if the connection or update service call fails.
-func (b *Builder) AgentApp(ctx context.Context) error {
-	// Create a gRPC client insecure connection to the server.
+func (b *Builder) AgentApp(ctx context.Context, reloads <-chan *config.Config) error {
+	setup, err := b.agentSetup(nil)
+	if err != nil {
+		return err
+	}
+
+	// Expose the metrics of the agent if requested.
+	// The metrics are kept across reloads, the listen address is not reloaded.
+	if setup.options.Metrics, err = b.metrics(ctx); err != nil {
+		return err
+	}
+
+	// Export the traces and the metrics over OTLP if requested.
+	// The exporters are kept across reloads, the endpoint is not reloaded.
+	if setup.telemetry, err = b.telemetry(ctx, setup.ids); err != nil {
+		return err
+	}
+
+	if setup.telemetry != nil {
+		setup.options.Tracer = setup.telemetry.Tracer()
+
+		// Flush the pending telemetry when the agent stops.
+		defer func() {
+			flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), telemetryFlushTimeout)
+			defer cancel()
+
+			if err := setup.telemetry.Shutdown(flushCtx); err != nil {
+				zerolog.Ctx(ctx).Error().Err(err).Msg("failed to flush telemetry")
+			}
+		}()
+	}
+
+	// Run the hooks on the changes of the connection, the running hooks are awaited when the agent stops.
+	var observers []app.Observer
+
+	runner, err := b.hooks(ctx, setup)
+	if err != nil {
+		return err
+	}
+
+	if runner != nil {
+		observers = append(observers, hookObserver{hooks: runner})
+		defer runner.Wait()
+	}
+
+	// Record the changes of the connection in the history, the stop is recorded when the agent returns.
+	if journal := b.history(ctx); journal != nil {
+		observers = append(observers, journal)
+		defer journal.stop()
+	}
+
+	// Show the connection state on the status LED, it is turned off when the agent returns.
+	if indicator := b.statusLED(ctx); indicator != nil {
+		observers = append(observers, indicator)
+		defer indicator.off()
+	}
+
+	// Report the status of the agent and accept commands over the control socket.
+	// The pauses requested before the agent was restarted are restored from the state file.
+	setup.options.Status = app.NewStatus(observers...)
+	setup.options.Pauses = b.pauses(ctx)
+	b.controlSocket(ctx, setup.options.Status, setup.options.Pauses)
+
+	conn, err := b.connect(ctx, setup)
+	if err != nil {
+		return err
+	}
+
+	// Close the connection when the function returns.
+	defer func() { conn.close() }()
+
+	// The reloaded settings are passed to the running agent loop.
+	updates := make(chan app.Reload)
+	current := b
+
+	for {
+		// The agent loop is restarted with a new connection when the target or the credentials change.
+		runCtx, cancel := context.WithCancelCause(ctxid.WithIDs(ctx, setup.ids...))
+		done := make(chan error, 1)
+
+		options := setup.options
+		options.Reloads = updates
+
+		// Call the app.Agent function to start the agent.
+		// The agent sends update requests to the server using the client stream.
+		go func(client vakeel_way.StateServiceClient) {
+			done <- app.Agent(runCtx, client, options)
+		}(vakeel_way.NewStateServiceClient(conn.conn))
+
+		reconnect := false
+
+		for !reconnect {
+			select {
+			case err := <-done:
+				cancel(nil)
+
+				return err
+			case cfg := <-reloads:
+				next := New(cfg)
+
+				nextSetup, err := next.agentSetup(&setup)
+				if err != nil {
+					zerolog.Ctx(ctx).Error().Err(err).Str(app.EventFieldName, app.EventReloadFailed).
+						Msg("invalid configuration, keeping the current one")
+
+					continue
+				}
+
+				// Keep the connection if neither the target nor the credentials changed.
+				if sameConnection(current, next, setup, nextSetup) {
+					select {
+					case updates <- app.Reload{IDs: nextSetup.ids, Options: nextSetup.options}:
+					case err := <-done:
+						cancel(nil)
+
+						return err
+					}
+
+					applyReload(ctx, current, next)
+
+					current, setup = next, nextSetup
+					updateHookEnv(runner, setup)
+
+					zerolog.Ctx(ctx).Info().Str(app.EventFieldName, app.EventReload).Msg("configuration reloaded")
+
+					continue
+				}
+
+				nextConn, err := next.connect(ctx, nextSetup)
+				if err != nil {
+					zerolog.Ctx(ctx).Error().Err(err).Str(app.EventFieldName, app.EventReloadFailed).
+						Msg("invalid configuration, keeping the current one")
+
+					continue
+				}
+
+				zerolog.Ctx(ctx).Info().Str(app.EventFieldName, app.EventReconnect).Str("target", nextSetup.target).
+					Msg("configuration reloaded, reconnecting")
+
+				// Close the current stream gracefully before closing its connection.
+				cancel(app.ErrReconnect)
+				<-done
+				conn.close()
+
+				applyReload(ctx, current, next)
+
+				conn, current, setup, reconnect = nextConn, next, nextSetup, true
+				updateHookEnv(runner, setup)
+			}
+		}
+	}
+}
+
+// agentSetup validates the configuration of the agent and derives its state.
+//
+// Parameters:
+//   - previous: The state derived from the previous configuration on reload, nil otherwise.
+//     Its reconnection policy is kept if the configuration of the policy did not change.
+//
+// Returns:
+//   - The state of the agent.
+//   - An error if the configuration is invalid.
+func (b *Builder) agentSetup(previous *agentSetup) (agentSetup, error) {
+	// Validate the intervals before connecting to the server.
+	if b.config.Interval <= 0 {
+		return agentSetup{}, errInvalidInterval
+	}
+
+	if b.config.RetryInterval < 0 {
+		return agentSetup{}, errInvalidRetryInterval
+	}
+
+	// Validate the log level, it is applied on reload.
+	if _, err := b.logLevel(); err != nil {
+		return agentSetup{}, err
+	}
+
+	// Load the auth token of the agent.
+	token, err := b.token()
+	if err != nil {
+		return agentSetup{}, err
+	}
+
+	// Create the reconnection policy from the configuration.
+	backoff, err := app.NewBackoff(
+		b.config.Backoff.Initial,
+		b.config.Backoff.Max,
+		b.config.Backoff.Multiplier,
+		b.config.Backoff.ResetAfter,
+	)
+	if err != nil {
+		return agentSetup{}, err
+	}
+
+	// Keep the state of the reconnection policy across reloads.
+	if previous != nil && previous.backoffConfig == b.config.Backoff {
+		backoff = previous.options.Backoff
+	}
+
+	// Parse the agent IDs from the configuration to label them in logs.
+	identities, err := b.Identities()
+	if err != nil {
+		return agentSetup{}, err
+	}
+
+	labels := labelsOf(identities)
+	ids := config.UUIDs(identities)
+
+	// Parse the windows in which the agent IDs send update requests.
+	schedules, err := b.schedules(ids)
+	if err != nil {
+		return agentSetup{}, err
+	}
+
+	// Spread the update requests of agents over the interval if requested.
+	// The offset is derived from the first agent ID, so it is stable across restarts.
+	var phase time.Duration
+	if b.config.Phase {
+		phase = app.PhaseOffset(ids[0], b.config.Interval)
+	}
+
+	// Keep recording the metrics, the traces, the status and the pauses across reloads.
+	var (
+		recorder app.Metrics
+		tracer   trace.Tracer
+		exporter *telemetry.Telemetry
+		status   *app.Status
+		pauses   *app.Pauses
+	)
+
+	if previous != nil {
+		recorder, tracer, exporter = previous.options.Metrics, previous.options.Tracer, previous.telemetry
+		status, pauses = previous.options.Status, previous.options.Pauses
+	}
+
+	return agentSetup{
+		target:        net.JoinHostPort(b.config.Host, strconv.Itoa(b.config.Port)),
+		token:         token,
+		ids:           ids,
+		backoffConfig: b.config.Backoff,
+		telemetry:     exporter,
+		// The setup is only derived for the agent.
+		watchCertificate: true,
+		options: app.Options{
+			Interval:      b.config.Interval,
+			RetryInterval: b.config.RetryInterval,
+			Phase:         phase,
+			Labels:        labels,
+			Schedules:     schedules,
+			Backoff:       backoff,
+			DrainTimeout:  b.config.DrainTimeout,
+			Metrics:       recorder,
+			Tracer:        tracer,
+			Status:        status,
+			Pauses:        pauses,
+		},
+	}, nil
+}
+
+// labelsOf returns the human-readable names of the agent IDs that have a label.
+func labelsOf(identities []config.Identity) map[uuid.UUID]string {
+	labels := make(map[uuid.UUID]string, len(identities))
+	for _, identity := range identities {
+		if identity.Label != "" {
+			labels[identity.ID] = identity.Label
+		}
+	}
+
+	return labels
+}
+
+// schedules parses the windows in which the agent IDs send update requests.
+//
+// Parameters:
+//   - ids: The configured agent IDs.
+//
+// Returns:
+//   - The windows of the scheduled agent IDs.
+//   - An error if a schedule is invalid or refers to an agent ID that is not configured.
+func (b *Builder) schedules(ids []uuid.UUID) (map[uuid.UUID]app.Schedule, error) {
+	schedules := make(map[uuid.UUID]app.Schedule, len(b.config.Schedules))
+
+	for _, value := range b.config.Schedules {
+		rawID, expression, ok := strings.Cut(value, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w, got %q", errInvalidSchedule, value)
+		}
+
+		id, err := ctxid.Parse(strings.TrimSpace(rawID))
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(ids, id) {
+			return nil, fmt.Errorf("%w: %s", errUnknownScheduleID, id)
+		}
+
+		if _, ok := schedules[id]; ok {
+			return nil, fmt.Errorf("%w: %s", errDuplicateSchedule, id)
+		}
+
+		window, err := schedule.Parse(strings.TrimSpace(expression))
+		if err != nil {
+			return nil, err
+		}
+
+		schedules[id] = window
+	}
+
+	return schedules, nil
+}
+
+// metrics starts the metrics endpoint if a listen address is configured.
+//
+// Parameters:
+//   - ctx: The context that stops the metrics endpoint, it provides the logger.
+//
+// Returns:
+//   - The recorder of the agent activity, nil if the metrics are disabled.
+//   - An error if the listen address cannot be bound.
+func (b *Builder) metrics(ctx context.Context) (app.Metrics, error) {
+	if b.config.MetricsListen == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	listener, err := net.Listen("tcp", b.config.MetricsListen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen for metrics: %w", err)
+	}
+
+	recorder := metrics.New()
+
+	zerolog.Ctx(ctx).Info().Str("address", listener.Addr().String()).Msg("serving metrics")
+
+	go func() {
+		if err := recorder.Serve(ctx, listener); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("metrics endpoint failed")
+		}
+	}()
+
+	return recorder, nil
+}
+
+// telemetry creates the OTLP exporters if an endpoint is configured.
+//
+// Parameters:
+//   - ctx: The context of the exporters.
+//   - ids: The agent IDs, the first one identifies the instance of the agent.
+//
+// Returns:
+//   - The exporters of the traces and the metrics, nil if the export is disabled.
+//   - An error if the exporters cannot be created.
+func (b *Builder) telemetry(ctx context.Context, ids []uuid.UUID) (*telemetry.Telemetry, error) {
+	if b.config.OTLP.Endpoint == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	return telemetry.New(ctx, telemetry.Options{
+		Endpoint:   b.config.OTLP.Endpoint,
+		Insecure:   b.config.OTLP.Insecure,
+		InstanceID: ids[0].String(),
+	})
+}
+
+// connect creates the client connection to the server.
+//
+// Parameters:
+//   - ctx: The context that stops the client certificate watcher, it is also stopped when the connection is closed.
+//   - setup: The state of the agent that provides the target, the auth token and whether the certificate is watched.
+//
+// Returns:
+//   - The client connection.
+//   - An error if the transport security settings are invalid.
+func (b *Builder) connect(ctx context.Context, setup agentSetup) (*connection, error) {
+	ctx, stop := context.WithCancel(ctx)
+
+	opts, err := b.transportOptions(ctx)
+	if err != nil {
+		stop()
+
+		return nil, err
+	}
+
+	// Watch the client certificate for rotation and expiry until the connection is closed.
+	if setup.watchCertificate && opts.Certificates != nil {
+		go opts.Certificates.Watch(ctx, certWatchInterval)
+	}
+
+	// Create the transport credentials of the connection.
+	// TLS is used unless plaintext is requested explicitly.
+	creds, err := transport.Credentials(opts)
+	if err != nil {
+		stop()
+
+		return nil, err
+	}
+
+	// Create a gRPC client connection to the server.
 	// The connection is established using the host and port from the configuration.
 	// The connection is configured with keep-alive parameters to send pings to the server
 	// every 10 seconds if there is no activity and to consider the connection dead if
 	// a ping ack is not received within 1 second.
-	conn, err := grpc.NewClient(
-		net.JoinHostPort(b.config.Host, strconv.Itoa(b.config.Port)),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	dialOptions := []grpc.DialOption{
+		grpc.WithTransportCredentials(creds),
 		grpc.WithKeepaliveParams(keepalive.ClientParameters{
 			Time:                keepAliveTime,
 			Timeout:             keepAliveTimeout,
 			PermitWithoutStream: allowWithoutStreams,
 		}),
-	)
+	}
+
+	// Trace the calls and record the RPC metrics if the telemetry export is enabled.
+	if setup.telemetry != nil {
+		dialOptions = append(dialOptions, grpc.WithStatsHandler(setup.telemetry.StatsHandler()))
+	}
+
+	// Attach the auth token to the update stream as a bearer token.
+	if setup.token != "" {
+		dialOptions = append(dialOptions, grpc.WithPerRPCCredentials(transport.NewTokenCredentials(setup.token)))
+	}
+
+	conn, err := grpc.NewClient(setup.target, dialOptions...)
 	if err != nil {
-		return err
+		stop()
+
+		return nil, err
 	}
 
-	// Close the connection when the function returns.
-	defer conn.Close()
+	return &connection{conn: conn, stop: stop}, nil
+}
+
+// sameConnection reports whether the connection of the current configuration can be kept
+// for the next one, i.e. neither the target nor the credentials changed.
+// The loaded tokens are compared rather than where they come from.
+func sameConnection(current, next *Builder, currentSetup, nextSetup agentSetup) bool {
+	currentTLS, nextTLS := current.config.TLS, next.config.TLS
+	currentTLS.Token, currentTLS.TokenFile = "", ""
+	nextTLS.Token, nextTLS.TokenFile = "", ""
 
-	// Create a client for the vakeel_way.StateService.
-	serviceClient := vakeel_way.NewStateServiceClient(conn)
+	return currentSetup.target == nextSetup.target &&
+		currentSetup.token == nextSetup.token &&
+		reflect.DeepEqual(currentTLS, nextTLS)
+}
+
+// applyReload applies the settings of the next configuration that the agent loop does not handle.
+//
+// The log level is changed if it was reconfigured, the other changed settings are only applied
+// when the agent starts, so they are named in a warning.
+func applyReload(ctx context.Context, current, next *Builder) {
+	if next.config.Log.Level != current.config.Log.Level {
+		// The level has been validated with the configuration.
+		level, _ := next.logLevel()
+		zerolog.SetGlobalLevel(level)
+	}
 
-	// Call the app.Agent function to start the agent.
-	// The agent sends update requests to the server using the client stream.
-	// The function returns an error if sending the update request fails.
-	return app.Agent(ctx, serviceClient)
+	if settings := restartSettings(current.config, next.config); len(settings) > 0 {
+		zerolog.Ctx(ctx).Warn().Str(app.EventFieldName, app.EventReload).Strs("settings", settings).
+			Msg("the changed settings are only applied after a restart of the agent")
+	}
+}
+
+// restartSettings returns the names of the settings that changed between the configurations,
+// but are only applied when the agent starts.
+func restartSettings(current, next *config.Config) []string {
+	settings := []struct {
+		name    string
+		changed bool
+	}{
+		{name: "log-format", changed: current.Log.Format != next.Log.Format},
+		{name: "log-sink", changed: current.Log.Sink != next.Log.Sink},
+		{name: "log-file", changed: current.Log.File != next.Log.File},
+		{name: "log-max-size", changed: current.Log.MaxSize != next.Log.MaxSize},
+		{name: "log-max-backups", changed: current.Log.MaxBackups != next.Log.MaxBackups},
+		{name: "metrics-listen", changed: current.MetricsListen != next.MetricsListen},
+		{name: "otlp-endpoint", changed: current.OTLP.Endpoint != next.OTLP.Endpoint},
+		{name: "otlp-insecure", changed: current.OTLP.Insecure != next.OTLP.Insecure},
+		{name: "control-socket", changed: current.ControlSocket != next.ControlSocket},
+		{name: "hook-connect", changed: current.Hooks.Connect != next.Hooks.Connect},
+		{name: "hook-disconnect", changed: current.Hooks.Disconnect != next.Hooks.Disconnect},
+		{name: "hook-outage", changed: current.Hooks.Outage != next.Hooks.Outage},
+		{name: "hook-outage-after", changed: current.Hooks.OutageAfter != next.Hooks.OutageAfter},
+		{name: "hook-timeout", changed: current.Hooks.Timeout != next.Hooks.Timeout},
+		{name: "hook-min-interval", changed: current.Hooks.MinInterval != next.Hooks.MinInterval},
+		{name: "led", changed: current.LED.Name != next.LED.Name},
+		{name: "led-root", changed: current.LED.Root != next.LED.Root},
+		{name: "history-max-size", changed: current.HistoryMaxSize != next.HistoryMaxSize},
+		{name: "state-dir", changed: current.StateDir != next.StateDir},
+	}
+
+	var names []string
+
+	for _, setting := range settings {
+		if setting.changed {
+			names = append(names, setting.name)
+		}
+	}
+
+	return names
 }
 
 // AgentRegisterApp is a method of the Builder struct.
@@ -72,15 +616,31 @@ func (b *Builder) AgentApp(ctx context.Context) error {
 // Returns:
 // An error if the registration fails.
 func (b *Builder) AgentRegisterApp(ctx context.Context) error {
-	// Create a new templater.New instance with the context ID, host, and port from the config.
+	// Parse the agent IDs from the configuration.
+	identities, err := b.Identities()
+	if err != nil {
+		return err
+	}
+
+	ids := make([]string, 0, len(identities))
+	for _, identity := range identities {
+		ids = append(ids, identity.String())
+	}
+
+	// Load the auth token, it is written into the service file outside of the command line.
+	token, err := b.token()
+	if err != nil {
+		return err
+	}
+
+	// Create a new templater.New instance with the IDs, host, and port from the config.
 	// The templater.New instance generates the stub agent template.
-	generate, err := templater.New(ctxid.ID(ctx), b.config.Host, b.config.Port)
+	generate, err := templater.New(ids, b.config.Host, b.config.Port, b.serviceArgs(), token, b.config.Sources.File)
 	if err != nil {
 		return err
 	}
 
 	// Call the AgentRegister function of the app package.
-	// It creates a gRPC client insecure connection to the server.
 	// It registers the agent application with the server.
 	// It returns an error if the registration fails.
 	//
@@ -91,3 +651,118 @@ func (b *Builder) AgentRegisterApp(ctx context.Context) error {
 	// An error if the registration fails.
 	return app.AgentRegister(ctx, generate)
 }
+
+// transportOptions creates the transport security settings of the connection to the server.
+//
+// The client certificate is reloaded on every handshake, it is not watched, see connect.
+//
+// Parameters:
+//   - ctx: The context that provides the logger of the client certificate reloads.
+//
+// Returns:
+//   - The transport security settings of the connection.
+//   - An error if the client certificate cannot be loaded.
+func (b *Builder) transportOptions(ctx context.Context) (transport.Options, error) {
+	opts := transport.Options{
+		Plaintext:  b.config.TLS.Plaintext,
+		CAFile:     b.config.TLS.CAFile,
+		ServerName: b.config.TLS.ServerName,
+		Pins:       b.config.TLS.Pins,
+	}
+
+	// Load the client certificate, it is reloaded once it is rotated.
+	if b.config.TLS.CertFile != "" || b.config.TLS.KeyFile != "" {
+		reloader, err := transport.NewCertReloader(
+			b.config.TLS.CertFile,
+			b.config.TLS.KeyFile,
+			b.config.TLS.CertExpiryWarning,
+			*zerolog.Ctx(ctx),
+		)
+		if err != nil {
+			return transport.Options{}, err
+		}
+
+		opts.Certificates = reloader
+	}
+
+	return opts, nil
+}
+
+// token loads the auth token of the agent from the configured sources.
+//
+// Returns:
+//   - The auth token, or an empty string if the agent does not authenticate.
+//   - An error if the token cannot be loaded or would be sent without TLS.
+func (b *Builder) token() (string, error) {
+	token, err := transport.LoadToken(b.config.TLS.Token, b.config.TLS.TokenFile)
+	if err != nil {
+		return "", err
+	}
+
+	if token != "" && b.config.TLS.Plaintext {
+		return "", errTokenPlaintext
+	}
+
+	return token, nil
+}
+
+// serviceArgs returns the additional agent flags of the generated service file.
+//
+// If the configuration was read from a file, the agent reads the same file, so only
+// the flags set on the command line are passed to keep their precedence over the file.
+// Otherwise the transport security settings are passed.
+func (b *Builder) serviceArgs() []string {
+	if b.config.Sources.File == "" {
+		return b.transportArgs()
+	}
+
+	args := make([]string, 0, len(b.config.Sources.Args))
+
+	for _, arg := range b.config.Sources.Args {
+		// The auth token is passed outside of the command line.
+		if strings.HasPrefix(arg, "--token=") {
+			continue
+		}
+
+		args = append(args, arg)
+	}
+
+	return args
+}
+
+// transportArgs returns the agent flags that reproduce the transport security settings
+// of the configuration in the generated service file.
+func (b *Builder) transportArgs() []string {
+	var args []string
+
+	if b.config.TLS.Plaintext {
+		args = append(args, "--plaintext")
+	}
+
+	if b.config.TLS.CAFile != "" {
+		args = append(args, "--ca-file="+b.config.TLS.CAFile)
+	}
+
+	if b.config.TLS.ServerName != "" {
+		args = append(args, "--server-name="+b.config.TLS.ServerName)
+	}
+
+	for _, pin := range b.config.TLS.Pins {
+		args = append(args, "--pin="+pin)
+	}
+
+	if b.config.TLS.CertFile != "" {
+		args = append(args, "--cert-file="+b.config.TLS.CertFile)
+	}
+
+	if b.config.TLS.KeyFile != "" {
+		args = append(args, "--key-file="+b.config.TLS.KeyFile)
+	}
+
+	// The expiry of the client certificate is only watched if there is one.
+	if b.config.TLS.CertFile != "" {
+		args = append(args, "--cert-expiry-warning="+b.config.TLS.CertExpiryWarning.String())
+	}
+
+	return args
+}
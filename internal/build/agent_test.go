@@ -0,0 +1,125 @@
+package build
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bavix/vakeel/internal/config"
+)
+
+// Agent IDs of the test configurations.
+const (
+	testID      = "5b1d1c4e-7a1f-4f3e-9d3a-2a6f0f5e8c11"
+	testOtherID = "0f8d2c7a-3b4e-4c5d-8e9f-1a2b3c4d5e6f"
+)
+
+// newTestConfig returns a valid agent configuration.
+func newTestConfig() *config.Config {
+	return &config.Config{
+		Host:          "way.example.com",
+		Port:          443,
+		IDs:           []string{testID},
+		Interval:      30 * time.Second,
+		RetryInterval: time.Second,
+		DrainTimeout:  5 * time.Second,
+		Backoff:       config.Backoff{Initial: time.Second, Max: time.Minute, Multiplier: 2},
+		TLS:           config.TLS{Token: "secret"},
+	}
+}
+
+func TestSameConnection(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	sameToken, otherToken := filepath.Join(dir, "same"), filepath.Join(dir, "other")
+
+	tests := []struct {
+		name   string
+		change func(cfg *config.Config)
+		want   bool
+	}{
+		{name: "nothing", change: func(*config.Config) {}, want: true},
+		{name: "interval", change: func(cfg *config.Config) { cfg.Interval = time.Minute }, want: true},
+		{name: "agent IDs", change: func(cfg *config.Config) { cfg.IDs = append(cfg.IDs, testOtherID+"=backup") }, want: true},
+		{name: "schedule", change: func(cfg *config.Config) { cfg.Schedules = []string{testID + "=* 8-18 * * *"} }, want: true},
+		{name: "backoff", change: func(cfg *config.Config) { cfg.Backoff.Max = time.Hour }, want: true},
+		{name: "drain timeout", change: func(cfg *config.Config) { cfg.DrainTimeout = time.Second }, want: true},
+		{name: "hooks", change: func(cfg *config.Config) { cfg.Hooks.Connect = "/bin/true" }, want: true},
+		{name: "host", change: func(cfg *config.Config) { cfg.Host = "other.example.com" }, want: false},
+		{name: "port", change: func(cfg *config.Config) { cfg.Port = 8443 }, want: false},
+		{name: "same token file", change: func(cfg *config.Config) { cfg.TLS.Token, cfg.TLS.TokenFile = "", sameToken }, want: true},
+		{name: "token", change: func(cfg *config.Config) { cfg.TLS.Token = "rotated" }, want: false},
+		{name: "token file", change: func(cfg *config.Config) { cfg.TLS.Token, cfg.TLS.TokenFile = "", otherToken }, want: false},
+		{name: "CA file", change: func(cfg *config.Config) { cfg.TLS.CAFile = "/etc/vakeel/ca.pem" }, want: false},
+		{name: "server name", change: func(cfg *config.Config) { cfg.TLS.ServerName = "way.internal" }, want: false},
+		{name: "pins", change: func(cfg *config.Config) { cfg.TLS.Pins = []string{"sha256/pin"} }, want: false},
+		{name: "client certificate", change: func(cfg *config.Config) {
+			cfg.TLS.CertFile, cfg.TLS.KeyFile = "/etc/vakeel/client.pem", "/etc/vakeel/client.key"
+		}, want: false},
+	}
+
+	for path, token := range map[string]string{sameToken: "secret\n", otherToken: "rotated\n"} {
+		if err := os.WriteFile(path, []byte(token), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			current := New(newTestConfig())
+
+			setup, err := current.agentSetup(nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := newTestConfig()
+			test.change(cfg)
+			next := New(cfg)
+
+			nextSetup, err := next.agentSetup(&setup)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := sameConnection(current, next, setup, nextSetup); got != test.want {
+				t.Fatalf("sameConnection() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestRestartSettings(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		change func(cfg *config.Config)
+		want   []string
+	}{
+		{name: "nothing", change: func(*config.Config) {}, want: nil},
+		{name: "applied settings", change: func(cfg *config.Config) {
+			cfg.Interval, cfg.Host, cfg.Log.Level = time.Minute, "other.example.com", "debug"
+		}, want: nil},
+		{name: "log sink", change: func(cfg *config.Config) { cfg.Log.Sink = "journald" }, want: []string{"log-sink"}},
+		{name: "metrics and hooks", change: func(cfg *config.Config) {
+			cfg.MetricsListen, cfg.Hooks.Outage = ":9100", "/sbin/reboot"
+		}, want: []string{"metrics-listen", "hook-outage"}},
+		{name: "control socket", change: func(cfg *config.Config) { cfg.ControlSocket = "/run/other.sock" },
+			want: []string{"control-socket"}},
+	}
+
+	for _, test := range tests {
+		cfg := newTestConfig()
+		test.change(cfg)
+
+		if got := restartSettings(newTestConfig(), cfg); !slices.Equal(got, test.want) {
+			t.Errorf("%s: restartSettings() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,261 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/control"
+	"github.com/bavix/vakeel/internal/infra/maintenance"
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+// errPauseEnded is the error returned when a pause ends before it starts.
+var errPauseEnded = errors.New("the pause must end in the future")
+
+// errUnknownID is the error returned when an agent ID is not sent by the agent.
+var errUnknownID = errors.New("the agent does not send the ID")
+
+// controlSocket serves the control socket of the agent if a path is configured.
+//
+// The agent keeps running without the control socket if it cannot be created,
+// e.g. when another agent already uses the same path.
+//
+// Parameters:
+//   - ctx: The context that stops the control socket, it provides the logger.
+//   - status: The shared state of the agent.
+//   - pauses: The pauses of the update requests, they are saved to the state file when they change.
+func (b *Builder) controlSocket(ctx context.Context, status *app.Status, pauses *app.Pauses) {
+	if b.config.ControlSocket == "" {
+		return
+	}
+
+	path := config.InstancePath(b.config.ControlSocket, b.config.Instance)
+
+	listener, err := control.Listen(path)
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("control socket is unavailable")
+
+		return
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("path", path).Msg("serving control socket")
+
+	go func() {
+		agent := &controller{
+			status:  status,
+			pauses:  pauses,
+			file:    maintenance.NewFile(b.config.StateDir, b.config.Instance),
+			version: version(),
+		}
+
+		if err := control.Serve(ctx, listener, agent); err != nil {
+			zerolog.Ctx(ctx).Error().Err(err).Msg("control socket failed")
+		}
+	}()
+}
+
+// controller exposes the running agent to the control socket.
+type controller struct {
+	// status is the shared state of the agent.
+	status *app.Status
+	// pauses are the pauses of the update requests.
+	pauses *app.Pauses
+	// file is the state file the pauses are saved to.
+	file *maintenance.File
+	// version is the version of the agent.
+	version string
+}
+
+// Status returns the current status of the agent.
+func (c *controller) Status(context.Context) control.Status {
+	snapshot := c.status.Snapshot()
+
+	status := control.Status{
+		Version:        c.version,
+		PID:            os.Getpid(),
+		State:          string(snapshot.State),
+		Since:          snapshot.Since,
+		BackoffSeconds: snapshot.Backoff.Seconds(),
+		IDs:            make([]control.ID, 0, len(snapshot.IDs)),
+		Pauses:         make([]control.Pause, 0),
+		LogLevel:       zerolog.GlobalLevel().String(),
+	}
+
+	if !snapshot.LastSend.IsZero() {
+		status.LastSend = &snapshot.LastSend
+	}
+
+	if snapshot.LastError != nil {
+		status.LastError = snapshot.LastError.Error()
+	}
+
+	for _, id := range snapshot.IDs {
+		status.IDs = append(status.IDs, control.ID{ID: id.String(), Label: snapshot.Labels[id]})
+	}
+
+	// The pauses that ended are left out even if the agent has not noticed yet.
+	now := time.Now()
+
+	for _, pause := range c.pauses.List() {
+		if !now.Before(pause.Until) {
+			continue
+		}
+
+		item := control.Pause{Until: pause.Until}
+		if pause.ID != uuid.Nil {
+			item.ID = pause.ID.String()
+		}
+
+		status.Pauses = append(status.Pauses, item)
+	}
+
+	return status
+}
+
+// SetLogLevel changes the minimum level of the logged messages.
+func (c *controller) SetLogLevel(ctx context.Context, value string) error {
+	level, err := zerolog.ParseLevel(value)
+	if err != nil || level == zerolog.NoLevel {
+		return fmt.Errorf("%w, got %q", errInvalidLevel, value)
+	}
+
+	// Log the change before it is applied, so that it is not filtered out by a higher level.
+	zerolog.Ctx(ctx).Info().Str("log_level", level.String()).Msg("changing log level")
+	zerolog.SetGlobalLevel(level)
+
+	return nil
+}
+
+// Pause stops the update requests of the given agent IDs, or of all of them, until the given time.
+// The pauses are saved to the state file, so that they survive restarts of the agent.
+func (c *controller) Pause(ctx context.Context, values []string, until time.Time) error {
+	if !time.Now().Before(until) {
+		return errPauseEnded
+	}
+
+	ids, err := c.configuredIDs(values)
+	if err != nil {
+		return err
+	}
+
+	c.pauses.Pause(ids, until)
+
+	zerolog.Ctx(ctx).Info().Str(app.EventFieldName, app.EventPause).Strs("paused", targets(values)).Time("until", until).
+		Msg("update requests paused")
+
+	return c.save()
+}
+
+// Resume sends the update requests of the given agent IDs, or of all of them, again.
+func (c *controller) Resume(ctx context.Context, values []string) error {
+	ids, err := c.configuredIDs(values)
+	if err != nil {
+		return err
+	}
+
+	c.pauses.Resume(ids, c.status.Snapshot().IDs)
+
+	zerolog.Ctx(ctx).Info().Str(app.EventFieldName, app.EventResume).Strs("resumed", targets(values)).
+		Msg("update requests resumed")
+
+	return c.save()
+}
+
+// configuredIDs parses the agent IDs and checks that the agent sends them.
+func (c *controller) configuredIDs(values []string) ([]uuid.UUID, error) {
+	configured := c.status.Snapshot().IDs
+	ids := make([]uuid.UUID, 0, len(values))
+
+	for _, value := range values {
+		id, err := ctxid.Parse(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slices.Contains(configured, id) {
+			return nil, fmt.Errorf("%w: %s", errUnknownID, id)
+		}
+
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// save writes the pauses to the state file.
+func (c *controller) save() error {
+	pauses := c.pauses.List()
+	saved := make([]maintenance.Pause, 0, len(pauses))
+
+	for _, pause := range pauses {
+		saved = append(saved, maintenance.Pause{ID: pause.ID, Until: pause.Until})
+	}
+
+	if err := c.file.Save(saved); err != nil {
+		return fmt.Errorf("the pauses are in effect but cannot be saved: %w", err)
+	}
+
+	return nil
+}
+
+// pauses restores the pauses of the update requests from the state file.
+//
+// The agent starts without pauses if the state file cannot be read.
+//
+// Parameters:
+//   - ctx: The context that provides the logger.
+//
+// Returns:
+//   - The pauses that have not ended yet.
+func (b *Builder) pauses(ctx context.Context) *app.Pauses {
+	file := maintenance.NewFile(b.config.StateDir, b.config.Instance)
+
+	saved, err := file.Load(time.Now())
+	if err != nil {
+		zerolog.Ctx(ctx).Warn().Err(err).Msg("failed to restore the pauses, update requests are not paused")
+	}
+
+	pauses := make([]app.Pause, 0, len(saved))
+
+	for _, pause := range saved {
+		pauses = append(pauses, app.Pause{ID: pause.ID, Until: pause.Until})
+
+		paused := "all"
+		if pause.ID != uuid.Nil {
+			paused = pause.ID.String()
+		}
+
+		zerolog.Ctx(ctx).Info().Str(app.EventFieldName, app.EventPause).Str("paused", paused).
+			Time("until", pause.Until).Msg("update requests are paused")
+	}
+
+	return app.NewPauses(pauses)
+}
+
+// targets returns the agent IDs affected by a pause or a resume for the logs, "all" if none is given.
+func targets(values []string) []string {
+	if len(values) == 0 {
+		return []string{"all"}
+	}
+
+	return values
+}
+
+// version returns the version of the agent from the build information.
+func version() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+
+	return "unknown"
+}
@@ -0,0 +1,385 @@
+package build
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/identity"
+	"github.com/bavix/vakeel/internal/infra/templater"
+	"github.com/bavix/vakeel/internal/infra/transport"
+	"github.com/bavix/vakeel/pkg/featnix"
+)
+
+// errServiceDisabled is the error returned when the service of the agent is not started on boot.
+var errServiceDisabled = errors.New("the service is not enabled")
+
+// errBinaryMismatch is the error returned when the service runs another binary than the doctor.
+var errBinaryMismatch = errors.New("the service runs another binary")
+
+// errIDMismatch is the error returned when the service reports other agent IDs than the configured ones.
+var errIDMismatch = errors.New("the service reports other agent IDs")
+
+// errConfigMismatch is the error returned when the service reads another configuration file.
+var errConfigMismatch = errors.New("the service reads another configuration file")
+
+// DoctorApp checks the connection to the server and the installation of the agent step by step.
+//
+// The connection is checked with the settings of the agent: the host is resolved, the port is
+// dialed, the TLS handshake is made and an update stream is opened and closed without reporting
+// the agent IDs. The service file written by register must exist, be enabled, run this binary
+// and report the configured agent IDs.
+//
+// Parameters:
+//   - ctx: The context of the checks, it provides the logger.
+//   - timeout: The maximum run time of a check.
+//
+// Returns:
+//   - The outcomes of the checks.
+//   - An error if the configuration is invalid.
+func (b *Builder) DoctorApp(ctx context.Context, timeout time.Duration) ([]app.CheckResult, error) {
+	token, err := b.token()
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := b.transportOptions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// A diagnostic must not write the state, the agent ID is not generated if it does not exist yet.
+	identities, err := b.lookupIdentities()
+	if err != nil && !errors.Is(err, identity.ErrNoState) {
+		return nil, err
+	}
+
+	host := b.config.Host
+	target := net.JoinHostPort(host, strconv.Itoa(b.config.Port))
+
+	network := []app.Check{
+		{
+			Name: "dns",
+			Hint: "Check --host and the DNS servers of the device, e.g. /etc/resolv.conf.",
+			Run: func(ctx context.Context) (string, error) {
+				if net.ParseIP(host) != nil {
+					return host + " is an IP address", nil
+				}
+
+				addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+				if err != nil {
+					return "", err
+				}
+
+				return host + " resolves to " + strings.Join(addrs, ", "), nil
+			},
+		},
+		{
+			Name: "tcp",
+			Hint: "Check --port, the firewall and that the server is listening.",
+			Run: func(ctx context.Context) (string, error) {
+				var dialer net.Dialer
+
+				conn, err := dialer.DialContext(ctx, "tcp", target)
+				if err != nil {
+					return "", err
+				}
+
+				_ = conn.Close()
+
+				return "connected to " + conn.RemoteAddr().String(), nil
+			},
+		},
+		{
+			Name: "tls",
+			Hint: "Check --ca-file, --server-name, --pin and the client certificate, " +
+				"or pass --plaintext if the server does not use TLS.",
+			Run: func(ctx context.Context) (string, error) {
+				if opts.Plaintext {
+					return "", fmt.Errorf("%w, plaintext is requested", app.ErrCheckSkipped)
+				}
+
+				config, err := transportConfig(opts)
+				if err != nil {
+					return "", err
+				}
+
+				return handshake(ctx, target, config)
+			},
+		},
+		{
+			Name: "grpc",
+			Hint: "Check the auth token and that the server is a vakeel-way server.",
+			Run: func(ctx context.Context) (string, error) {
+				conn, err := b.connect(ctx, agentSetup{target: target, token: token})
+				if err != nil {
+					return "", err
+				}
+				defer conn.close()
+
+				if err := app.ProbeUpdate(ctx, vakeel_way.NewStateServiceClient(conn.conn)); err != nil {
+					return "", err
+				}
+
+				return "the server accepts the update stream", nil
+			},
+		},
+	}
+
+	return app.Diagnose(ctx, timeout, network, b.serviceChecks(identities)), nil
+}
+
+// serviceChecks returns the checks of the service installed by register.
+//
+// Parameters:
+//   - identities: The configured agent IDs, nil if the agent ID has not been generated yet.
+//
+// Returns:
+//   - The checks of the service, they depend on each other.
+func (b *Builder) serviceChecks(identities []config.Identity) []app.Check {
+	var service *templater.Service
+
+	enableHint := "Run systemctl enable --now vakeel.service."
+	if featnix.IsOpenWrt() {
+		enableHint = "Run /etc/init.d/vakeel enable && /etc/init.d/vakeel start."
+	}
+
+	return []app.Check{
+		{
+			Name: "service",
+			Hint: "Run vakeel register to install the service.",
+			Run: func(context.Context) (string, error) {
+				var err error
+
+				service, err = templater.InstalledService()
+				if errors.Is(err, templater.ErrNoServiceManager) {
+					return "", fmt.Errorf("%w, %w", app.ErrCheckSkipped, err)
+				}
+
+				if err != nil {
+					return "", err
+				}
+
+				return service.Path + " is installed", nil
+			},
+		},
+		{
+			Name: "enabled",
+			Hint: enableHint,
+			Run: func(context.Context) (string, error) {
+				if service == nil {
+					return "", fmt.Errorf("%w, no service", app.ErrCheckSkipped)
+				}
+
+				enabled, err := service.Enabled()
+				if err != nil {
+					return "", err
+				}
+
+				if !enabled {
+					return "", errServiceDisabled
+				}
+
+				return "the service is started on boot", nil
+			},
+		},
+		{
+			Name: "binary",
+			Hint: "Run vakeel register again with this binary.",
+			Run: func(context.Context) (string, error) {
+				if service == nil {
+					return "", fmt.Errorf("%w, no service", app.ErrCheckSkipped)
+				}
+
+				return checkBinary(service.AppPath())
+			},
+		},
+		{
+			Name: "ids",
+			Hint: "Run vakeel register again with the IDs of the agent.",
+			Run: func(context.Context) (string, error) {
+				if service == nil {
+					return "", fmt.Errorf("%w, no service", app.ErrCheckSkipped)
+				}
+
+				return b.checkServiceIDs(service, identities)
+			},
+		},
+	}
+}
+
+// transportConfig creates the TLS configuration of the handshake made by the doctor.
+func transportConfig(opts transport.Options) (*tls.Config, error) {
+	config, err := transport.Config(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// gRPC negotiates HTTP/2 over TLS.
+	config.NextProtos = []string{"h2"}
+
+	return config, nil
+}
+
+// handshake makes a TLS handshake with the server and describes the connection.
+func handshake(ctx context.Context, target string, config *tls.Config) (string, error) {
+	dialer := tls.Dialer{Config: config}
+
+	conn, err := dialer.DialContext(ctx, "tcp", target)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState() //nolint:forcetypeassert
+	detail := tls.VersionName(state.Version)
+
+	if len(state.PeerCertificates) > 0 {
+		leaf := state.PeerCertificates[0]
+		detail += fmt.Sprintf(", certificate of %s valid until %s",
+			leaf.Subject.CommonName, leaf.NotAfter.Format(time.DateOnly))
+	}
+
+	return detail, nil
+}
+
+// checkBinary checks that the service runs the same binary as the doctor.
+func checkBinary(appPath string) (string, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	if samePath(appPath, self) {
+		return "the service runs " + appPath, nil
+	}
+
+	if _, err := os.Stat(appPath); err != nil {
+		return "", fmt.Errorf("%w: %w", errBinaryMismatch, err)
+	}
+
+	return "", fmt.Errorf("%w: %s, this is %s", errBinaryMismatch, appPath, self)
+}
+
+// checkServiceIDs checks that the service reports the configured agent IDs.
+//
+// The IDs are compared if the service passes them on the command line or derives its ID from the
+// identity flags on the command line. Otherwise the service reads them from a configuration file,
+// which must be the one of the doctor, or from its UCI section, which is not checked.
+func (b *Builder) checkServiceIDs(service *templater.Service, identities []config.Identity) (string, error) {
+	if identities == nil {
+		return "", fmt.Errorf("%w, the agent ID has not been generated yet", app.ErrCheckSkipped)
+	}
+
+	if values := service.Flag("id"); len(values) > 0 {
+		serviceIDs, err := (&config.Config{IDs: values}).Identities()
+		if err != nil {
+			return "", err
+		}
+
+		want, got := config.UUIDs(identities), config.UUIDs(serviceIDs)
+		if !sameIDs(want, got) {
+			return "", fmt.Errorf("%w: %s, configured %s", errIDMismatch, joinIDs(got), joinIDs(want))
+		}
+
+		return "the service reports " + joinIDs(got), nil
+	}
+
+	if files := service.Flag("config"); len(files) > 0 {
+		if !samePath(files[0], b.config.Sources.File) {
+			return "", fmt.Errorf("%w: %s, pass --config=%s to check it", errConfigMismatch, files[0], files[0])
+		}
+
+		return "the service reads the IDs from " + files[0], nil
+	}
+
+	if len(service.Flag("uci-section")) > 0 {
+		return "", fmt.Errorf("%w, the service reads the IDs from its UCI section", app.ErrCheckSkipped)
+	}
+
+	// The service derives its ID from the identity flags, the omitted ones have their defaults.
+	id, err := deriveID(
+		lastOr(service.Flag("id-namespace"), identity.DefaultNamespace),
+		lastOr(service.Flag("state-dir"), config.DefaultStateDir()),
+		lastOr(service.Flag("id-source"), string(identity.SourceAuto)),
+		true,
+	)
+	if errors.Is(err, identity.ErrNoState) {
+		return "", fmt.Errorf("%w, the service has not generated its agent ID yet", app.ErrCheckSkipped)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	want, got := config.UUIDs(identities), []uuid.UUID{id}
+	if !sameIDs(want, got) {
+		return "", fmt.Errorf("%w: %s derived from --id-source, configured %s", errIDMismatch, joinIDs(got), joinIDs(want))
+	}
+
+	return "the service derives " + id.String() + " from --id-source", nil
+}
+
+// lastOr returns the last of the values of a flag, or the default value if the flag is not set.
+func lastOr(values []string, defaultValue string) string {
+	if len(values) == 0 {
+		return defaultValue
+	}
+
+	return values[len(values)-1]
+}
+
+// samePath reports whether the paths refer to the same file, following the symbolic links.
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+
+	resolve := func(path string) string {
+		if resolved, err := filepath.EvalSymlinks(path); err == nil {
+			return resolved
+		}
+
+		if abs, err := filepath.Abs(path); err == nil {
+			return abs
+		}
+
+		return path
+	}
+
+	return resolve(a) == resolve(b)
+}
+
+// sameIDs reports whether the lists contain the same agent IDs regardless of their order.
+func sameIDs(a, b []uuid.UUID) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+
+	compare := func(x, y uuid.UUID) int { return strings.Compare(x.String(), y.String()) }
+	slices.SortFunc(a, compare)
+	slices.SortFunc(b, compare)
+
+	return slices.Equal(a, b)
+}
+
+// joinIDs formats the agent IDs as a comma-separated list.
+func joinIDs(ids []uuid.UUID) string {
+	values := make([]string, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, id.String())
+	}
+
+	return strings.Join(values, ", ")
+}
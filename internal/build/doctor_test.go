@@ -0,0 +1,124 @@
+package build
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/templater"
+)
+
+func TestCheckServiceIDs(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	configFile := filepath.Join(dir, "config.yaml")
+	stateDir, emptyDir := filepath.Join(dir, "state"), filepath.Join(dir, "empty")
+
+	if err := os.Mkdir(stateDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(stateDir, "id"), []byte(testID+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	configured := []config.Identity{{ID: uuid.MustParse(testID)}}
+	other := []config.Identity{{ID: uuid.MustParse(testOtherID)}}
+
+	tests := []struct {
+		name       string
+		args       []string
+		identities []config.Identity
+		wantErr    error
+	}{
+		{name: "not generated yet", args: []string{"--id=" + testID}, wantErr: app.ErrCheckSkipped},
+		{name: "same IDs", args: []string{"--id", testOtherID, "--id=" + testID + "=router"},
+			identities: append(other, configured...)},
+		{name: "other IDs", args: []string{"--id=" + testOtherID}, identities: configured, wantErr: errIDMismatch},
+		{name: "same config", args: []string{"--config=" + configFile}, identities: configured},
+		{name: "other config", args: []string{"--config=/etc/vakeel/other.yaml"}, identities: configured,
+			wantErr: errConfigMismatch},
+		{name: "UCI section", args: []string{`--uci-section=@agent[0]`, "--instance=main"}, identities: configured,
+			wantErr: app.ErrCheckSkipped},
+		{name: "same derived ID", args: []string{"--id-source=state", "--state-dir=" + stateDir}, identities: configured},
+		{name: "other derived ID", args: []string{"--id-source=state", "--state-dir", stateDir}, identities: other,
+			wantErr: errIDMismatch},
+		{name: "derived ID not generated", args: []string{"--id-source=state", "--state-dir=" + emptyDir},
+			identities: configured, wantErr: app.ErrCheckSkipped},
+	}
+
+	builder := New(&config.Config{Sources: config.Sources{File: configFile}})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			service := &templater.Service{Command: append([]string{"/usr/bin/vakeel", "agent"}, test.args...)}
+
+			if _, err := builder.checkServiceIDs(service, test.identities); !errors.Is(err, test.wantErr) {
+				t.Fatalf("checkServiceIDs() error = %v, want %v", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file, link := filepath.Join(dir, "vakeel"), filepath.Join(dir, "link")
+
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{a: file, b: file, want: true},
+		{a: link, b: file, want: true},
+		{a: filepath.Join(dir, ".", "vakeel"), b: file, want: true},
+		{a: file, b: filepath.Join(dir, "other"), want: false},
+		{a: "", b: "", want: true},
+		{a: file, b: "", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := samePath(tt.a, tt.b); got != tt.want {
+			t.Errorf("samePath(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSameIDs(t *testing.T) {
+	t.Parallel()
+
+	first, second := uuid.MustParse(testID), uuid.MustParse(testOtherID)
+
+	tests := []struct {
+		a, b []uuid.UUID
+		want bool
+	}{
+		{a: []uuid.UUID{first, second}, b: []uuid.UUID{second, first}, want: true},
+		{a: []uuid.UUID{first}, b: []uuid.UUID{first, second}, want: false},
+		{a: []uuid.UUID{first}, b: []uuid.UUID{second}, want: false},
+		{a: nil, b: []uuid.UUID{}, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := sameIDs(tt.a, tt.b); got != tt.want {
+			t.Errorf("sameIDs(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
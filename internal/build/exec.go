@@ -0,0 +1,188 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/job"
+)
+
+// exitPingFailed is the exit code of exec when the job succeeded but the server did not acknowledge
+// the update request.
+const exitPingFailed = 1
+
+// errInvalidHeartbeat is the error returned when the heartbeat IDs are given without a heartbeat interval.
+var errInvalidHeartbeat = errors.New("heartbeat IDs require a heartbeat interval")
+
+// errNoHeartbeatIDs is the error returned when the heartbeats are requested without heartbeat IDs.
+var errNoHeartbeatIDs = errors.New("heartbeats require heartbeat IDs other than the agent IDs")
+
+// errHeartbeatOverlap is the error returned when a heartbeat ID is also an agent ID reported on success.
+var errHeartbeatOverlap = errors.New("heartbeat ID is also reported on success")
+
+// Job is a command run by exec on behalf of the agent.
+type Job struct {
+	// Args are the command and its arguments.
+	Args []string
+	// Stdin is the standard input of the command.
+	Stdin io.Reader
+	// Stdout is the standard output of the command.
+	Stdout io.Writer
+	// Stderr is the standard error of the command.
+	Stderr io.Writer
+	// Timeout is the maximum run time of the command, zero for no limit.
+	Timeout time.Duration
+	// PingTimeout is the maximum time to wait for the server to acknowledge an update request.
+	PingTimeout time.Duration
+	// Heartbeat is the duration between the update requests sent while the command runs, zero disables them.
+	Heartbeat time.Duration
+	// HeartbeatIDs are the IDs reported while the command runs, in the "<uuid>[=<label>]" format.
+	// They are required by the heartbeats and must differ from the agent IDs, which would otherwise
+	// be kept up by a stuck job.
+	HeartbeatIDs []string
+}
+
+// ExecApp runs the job and reports the agent IDs to the server once it exits successfully.
+//
+// Nothing is reported if the job fails, times out or cannot be run, so that the server raises an alert
+// once the agent IDs expire, like a dead man's switch. If requested, the heartbeat IDs are reported
+// while the job runs, so that a stuck job can be told from a finished one.
+//
+// Parameters:
+//   - ctx: The context that stops the job, it provides the logger.
+//   - j: The job.
+//
+// Returns:
+//   - The exit code of the job, 1 if it succeeded but the server did not acknowledge the update request.
+//   - An error if the configuration is invalid, the job is not run then.
+func (b *Builder) ExecApp(ctx context.Context, j Job) (int, error) {
+	token, err := b.token()
+	if err != nil {
+		return 0, err
+	}
+
+	identities, err := b.Identities()
+	if err != nil {
+		return 0, err
+	}
+
+	heartbeats, err := heartbeatIdentities(j, identities)
+	if err != nil {
+		return 0, err
+	}
+
+	target := net.JoinHostPort(b.config.Host, strconv.Itoa(b.config.Port))
+
+	conn, err := b.connect(ctx, agentSetup{target: target, token: token})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.close()
+
+	client := vakeel_way.NewStateServiceClient(conn.conn)
+	logger := zerolog.Ctx(ctx)
+
+	// Report the heartbeat IDs while the job runs.
+	var heartbeat sync.WaitGroup
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+
+	if j.Heartbeat > 0 {
+		heartbeat.Add(1)
+
+		go func() {
+			defer heartbeat.Done()
+
+			app.Heartbeat(heartbeatCtx, client, j.Heartbeat, j.PingTimeout, config.UUIDs(heartbeats), labelsOf(heartbeats))
+		}()
+	}
+
+	result, err := job.Run(ctx, job.Options{
+		Args:    j.Args,
+		Stdin:   j.Stdin,
+		Stdout:  j.Stdout,
+		Stderr:  j.Stderr,
+		Timeout: j.Timeout,
+	})
+
+	stopHeartbeat()
+	heartbeat.Wait()
+
+	switch {
+	case err != nil:
+		logger.Error().Err(err).Str(app.EventFieldName, app.EventJobFailed).Int("exit_code", result.ExitCode).
+			Msg("failed to run the job, no update request is sent")
+
+		return result.ExitCode, nil
+	case result.TimedOut:
+		logger.Error().Str(app.EventFieldName, app.EventJobFailed).Dur("timeout", j.Timeout).
+			Msg("the job timed out, no update request is sent")
+
+		return result.ExitCode, nil
+	case !result.Succeeded():
+		logger.Error().Str(app.EventFieldName, app.EventJobFailed).Int("exit_code", result.ExitCode).
+			Dur("duration", result.Duration).Msg("the job failed, no update request is sent")
+
+		return result.ExitCode, nil
+	}
+
+	logger.Info().Str(app.EventFieldName, app.EventJobSucceeded).Dur("duration", result.Duration).
+		Msg("the job succeeded")
+
+	pingCtx, cancel := context.WithTimeout(ctx, j.PingTimeout)
+	defer cancel()
+
+	if err := app.Ping(pingCtx, client, config.UUIDs(identities), labelsOf(identities)); err != nil {
+		logger.Error().Err(err).Str(app.EventFieldName, app.EventSendFailed).Msg("failed to report the job")
+
+		return exitPingFailed, nil
+	}
+
+	return 0, nil
+}
+
+// heartbeatIdentities returns the IDs reported while the job runs, none if the heartbeats are disabled.
+//
+// The heartbeat IDs must not be reported on success, otherwise a stuck job would keep them up
+// and could not be told from a finished one.
+func heartbeatIdentities(j Job, identities []config.Identity) ([]config.Identity, error) {
+	if j.Heartbeat <= 0 {
+		if len(j.HeartbeatIDs) > 0 {
+			return nil, errInvalidHeartbeat
+		}
+
+		return nil, nil
+	}
+
+	if len(j.HeartbeatIDs) == 0 {
+		return nil, errNoHeartbeatIDs
+	}
+
+	heartbeats, err := (&config.Config{IDs: j.HeartbeatIDs}).Identities()
+	if err != nil {
+		return nil, err
+	}
+
+	ids := config.UUIDs(identities)
+
+	for _, heartbeat := range heartbeats {
+		if slices.Contains(ids, heartbeat.ID) {
+			return nil, fmt.Errorf("%w: %s", errHeartbeatOverlap, heartbeat.ID)
+		}
+	}
+
+	return heartbeats, nil
+}
@@ -0,0 +1,106 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/status"
+
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/infra/history"
+)
+
+// history opens the connectivity history in the state directory unless it is disabled
+// and records the start of the agent.
+//
+// The history is not reloaded. The records that cannot be written are logged and lost.
+//
+// Parameters:
+//   - ctx: The context that provides the logger.
+//
+// Returns:
+//   - The observer that records the changes of the connection state, nil if the history is disabled.
+func (b *Builder) history(ctx context.Context) *historyObserver {
+	if b.config.HistoryMaxSize <= 0 {
+		return nil
+	}
+
+	observer := &historyObserver{
+		journal: history.NewJournal(b.config.StateDir, b.config.Instance, int64(b.config.HistoryMaxSize)*kilobyte),
+		logger:  *zerolog.Ctx(ctx),
+	}
+
+	observer.record(history.Record{At: time.Now(), Kind: history.KindStart})
+
+	return observer
+}
+
+// historyObserver records the changes of the connection state of the agent in the history.
+type historyObserver struct {
+	// journal is the connectivity history.
+	journal *history.Journal
+	// logger logs the records that cannot be written.
+	logger zerolog.Logger
+
+	// mu guards the field below.
+	mu sync.Mutex
+	// last is the kind of the last record, the repeated ones are not recorded.
+	last history.Kind
+}
+
+// StateChanged records the change of the connection state, with the gRPC code of the error
+// that made the agent disconnect.
+func (o *historyObserver) StateChanged(change app.StateChange) {
+	record := history.Record{At: change.At}
+
+	switch change.To {
+	case app.StateConnected:
+		record.Kind = history.KindUp
+	case app.StateReconnecting:
+		record.Kind = history.KindDown
+
+		if change.Err != nil {
+			record.Code = errorCode(change.Err)
+		}
+	case app.StateStopped:
+		record.Kind = history.KindStop
+	default:
+		return
+	}
+
+	o.record(record)
+}
+
+// stop records that the agent stopped, unless it has already been recorded.
+func (o *historyObserver) stop() {
+	o.StateChanged(app.StateChange{To: app.StateStopped, At: time.Now()})
+}
+
+// record appends the record to the history unless it repeats the last one.
+func (o *historyObserver) record(record history.Record) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if record.Kind == o.last {
+		return
+	}
+
+	o.last = record.Kind
+
+	if err := o.journal.Append(record); err != nil {
+		o.logger.Warn().Err(err).Str("path", o.journal.Path()).Msg("failed to record the connectivity history")
+	}
+}
+
+// errorCode returns the gRPC code of the error, "EOF" if the server closed the stream.
+func errorCode(err error) string {
+	if _, ok := status.FromError(err); !ok && errors.Is(err, io.EOF) {
+		return "EOF"
+	}
+
+	return status.Code(err).String()
+}
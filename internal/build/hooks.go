@@ -0,0 +1,93 @@
+package build
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/infra/hooks"
+)
+
+// errInvalidHookTimeout is the error returned when the timeout of the hooks is not positive.
+var errInvalidHookTimeout = errors.New("hook timeout must be positive")
+
+// errInvalidOutageAfter is the error returned when the outage threshold of the hooks is not positive.
+var errInvalidOutageAfter = errors.New("hook outage threshold must be positive")
+
+// hooks creates the hooks of the connection events if any command is configured.
+//
+// The hooks are passed the server and the agent IDs in the VAKEEL_SERVER and VAKEEL_IDS
+// environment variables, which are updated on reload by hookEnv. The commands are not reloaded.
+//
+// Parameters:
+//   - ctx: The context that stops the hooks, it provides the logger.
+//   - setup: The state of the agent.
+//
+// Returns:
+//   - The hooks, nil if no command is configured.
+//   - An error if the settings of the hooks are invalid.
+func (b *Builder) hooks(ctx context.Context, setup agentSetup) (*hooks.Hooks, error) {
+	cfg := b.config.Hooks
+
+	if cfg.Connect == "" && cfg.Disconnect == "" && cfg.Outage == "" {
+		return nil, nil //nolint:nilnil
+	}
+
+	if cfg.Timeout <= 0 {
+		return nil, errInvalidHookTimeout
+	}
+
+	if cfg.Outage != "" && cfg.OutageAfter <= 0 {
+		return nil, errInvalidOutageAfter
+	}
+
+	return hooks.New(ctx, hooks.Options{
+		Commands: map[hooks.Event]string{
+			hooks.EventConnect:    cfg.Connect,
+			hooks.EventDisconnect: cfg.Disconnect,
+			hooks.EventOutage:     cfg.Outage,
+		},
+		OutageAfter: cfg.OutageAfter,
+		Timeout:     cfg.Timeout,
+		MinInterval: cfg.MinInterval,
+		Env:         hookEnv(setup),
+	}), nil
+}
+
+// hookEnv returns the environment variables of the hooks that describe the agent.
+func hookEnv(setup agentSetup) []string {
+	ids := make([]string, 0, len(setup.ids))
+	for _, id := range setup.ids {
+		ids = append(ids, id.String())
+	}
+
+	return []string{
+		"VAKEEL_SERVER=" + setup.target,
+		"VAKEEL_IDS=" + strings.Join(ids, ","),
+	}
+}
+
+// updateHookEnv passes the reloaded server and agent IDs to the hooks, if any.
+func updateHookEnv(runner *hooks.Hooks, setup agentSetup) {
+	if runner != nil {
+		runner.SetEnv(hookEnv(setup))
+	}
+}
+
+// hookObserver runs the hooks on the changes of the connection state of the agent.
+type hookObserver struct {
+	// hooks are the hooks of the connection events.
+	hooks *hooks.Hooks
+}
+
+// StateChanged runs the connect hook once the agent is connected and the disconnect hook
+// once a stream fails. The outage hook is run by the hooks themselves.
+func (o hookObserver) StateChanged(change app.StateChange) {
+	switch {
+	case change.To == app.StateConnected:
+		o.hooks.Connected(change.At)
+	case change.From == app.StateConnected && change.To == app.StateReconnecting:
+		o.hooks.Disconnected(change.At, change.Err)
+	}
+}
@@ -0,0 +1,81 @@
+package build
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/identity"
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+// Identities returns the agent IDs from the configuration.
+//
+// If no agent ID is configured, a stable agent ID for the current machine is
+// derived from the configured identity source and used instead.
+//
+// Returns:
+//   - The parsed agent IDs in the configured order.
+//   - An error if the agent IDs are invalid or cannot be derived.
+func (b *Builder) Identities() ([]config.Identity, error) {
+	return b.identities(false)
+}
+
+// lookupIdentities returns the agent IDs like Identities, but never generates the agent ID in the state directory.
+//
+// Returns:
+//   - The parsed agent IDs in the configured order.
+//   - An error wrapping identity.ErrNoState if the agent ID has not been generated yet.
+func (b *Builder) lookupIdentities() ([]config.Identity, error) {
+	return b.identities(true)
+}
+
+// identities returns the configured agent IDs or the derived one, readOnly prevents generating it.
+func (b *Builder) identities(readOnly bool) ([]config.Identity, error) {
+	if len(b.config.IDs) == 0 {
+		id, err := deriveID(b.config.IDNamespace, b.config.StateDir, b.config.IDSource, readOnly)
+		if err != nil {
+			return nil, err
+		}
+
+		return []config.Identity{{ID: id}}, nil
+	}
+
+	return b.config.Identities()
+}
+
+// deriveID derives the agent ID from the identity source.
+//
+// Parameters:
+//   - namespace: The namespace of the agent IDs derived from the machine ID.
+//   - stateDir: The directory where the generated agent ID is persisted.
+//   - source: The source the agent ID is derived from.
+//   - readOnly: Whether identity.ErrNoState is returned instead of generating the agent ID.
+//
+// Returns:
+//   - The agent ID.
+//   - An error if the namespace is invalid or the agent ID cannot be derived.
+func deriveID(namespace, stateDir, source string, readOnly bool) (uuid.UUID, error) {
+	// Parse the namespace of the agent IDs derived from the machine ID.
+	// The nil UUID is rejected, it would make every namespace collide.
+	parsed, err := ctxid.Parse(namespace)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid identity namespace: %w", err)
+	}
+
+	provider := identity.New(parsed, stateDir)
+
+	// Derive the agent ID from the configured source.
+	derive := provider.ID
+	if readOnly {
+		derive = provider.Lookup
+	}
+
+	id, err := derive(identity.Source(source))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to derive agent ID: %w", err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,76 @@
+package build
+
+import (
+	"context"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/infra/led"
+)
+
+// ledBlink is the on and off time of the status LED while the agent is not connected.
+const ledBlink = 500 * time.Millisecond
+
+// statusLED opens the status LED if one is configured and shows that the agent is connecting.
+//
+// An unavailable LED is logged and the agent runs without it. The LED is not reloaded.
+//
+// Parameters:
+//   - ctx: The context that provides the logger.
+//
+// Returns:
+//   - The observer that shows the connection state on the LED, nil if there is no LED.
+func (b *Builder) statusLED(ctx context.Context) *ledObserver {
+	cfg := b.config.LED
+	if cfg.Name == "" {
+		return nil
+	}
+
+	logger := zerolog.Ctx(ctx).With().Str("led", cfg.Name).Logger()
+
+	device, err := led.Open(cfg.Root, cfg.Name)
+	if err != nil {
+		logger.Warn().Err(err).Msg("status LED is unavailable")
+
+		return nil
+	}
+
+	observer := &ledObserver{led: device, logger: logger}
+	observer.StateChanged(app.StateChange{To: app.StateConnecting})
+
+	return observer
+}
+
+// ledObserver shows the connection state of the agent on the status LED:
+// solid while connected, blinking while connecting or reconnecting and off once stopped.
+type ledObserver struct {
+	// led is the status LED.
+	led *led.LED
+	// logger logs the failures to set the LED.
+	logger zerolog.Logger
+}
+
+// StateChanged sets the status LED to the new connection state.
+func (o *ledObserver) StateChanged(change app.StateChange) {
+	var err error
+
+	switch change.To {
+	case app.StateConnected:
+		err = o.led.On()
+	case app.StateConnecting, app.StateReconnecting:
+		err = o.led.Blink(ledBlink, ledBlink)
+	case app.StateStopped:
+		err = o.led.Off()
+	}
+
+	if err != nil {
+		o.logger.Warn().Err(err).Msg("failed to set the status LED")
+	}
+}
+
+// off turns the status LED off once the agent returned.
+func (o *ledObserver) off() {
+	o.StateChanged(app.StateChange{To: app.StateStopped})
+}
@@ -2,41 +2,163 @@ package build
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"os"
-	"time"
+	"strconv"
 
 	"github.com/rs/zerolog"
+
+	"github.com/bavix/vakeel/internal/infra/logging"
+	"github.com/bavix/vakeel/pkg/ctxid"
 )
 
+// levelEnv is the environment variable with the log level used when the level is not configured.
+const levelEnv = "LOG_LEVEL"
+
+// defaultLevel is the log level used when the level is configured nowhere.
+const defaultLevel = zerolog.InfoLevel
+
+// kilobyte is the unit of the maximum sizes of the log file and the history.
+const kilobyte = 1024
+
+// appName is the name of the application in syslog and journald.
+const appName = "vakeel"
+
+// errInvalidLevel is the error returned when the log level is not supported.
+var errInvalidLevel = errors.New("log level must be trace, debug, info, warn, error, fatal, panic or disabled")
+
+// errInvalidSink is the error returned when the log sink is not supported.
+var errInvalidSink = errors.New("log sink must be stdout, syslog or journald")
+
+// errLogFileSink is the error returned when the log file is combined with the syslog or journald sink.
+var errLogFileSink = errors.New("the log file can only be used with the stdout sink")
+
 // Logger creates a new context with a logger attached to it.
 //
-// It creates a logger with the log level specified in the environment variable LOG_LEVEL.
+// The logger writes the messages of the configured level and above to the configured sink:
+// the standard output or the rotated log file in the configured format, syslog or journald.
+// If the level is not configured, the LOG_LEVEL environment variable or "info" is used.
 // The logger is then attached to the given context.
 //
 // Parameters:
-//   - ctx: The context to attach the logger to.
+//   - ctx: The context to attach the logger to. The agent IDs attached to it with ctxid.WithIDs
+//     are added to the messages of the syslog and journald sinks.
 //
 // Returns:
 //   - The context with the logger attached.
-func (b *Builder) Logger(ctx context.Context) context.Context {
-	// Parse the log level from the environment variable LOG_LEVEL.
-	level, err := zerolog.ParseLevel(os.Getenv("LOG_LEVEL"))
+//   - An error if the logging configuration is invalid or the log file cannot be opened.
+func (b *Builder) Logger(ctx context.Context) (context.Context, error) {
+	level, err := b.logLevel()
 	if err != nil {
-		// If the log level is invalid, log the error and stop the application.
-		log.Fatal(err)
+		return ctx, err
 	}
 
-	// Create a new logger with the specified log level and time format.
-	// The time format is set to RFC3339Nano, which is the most precise time format.
-	logger := zerolog.New(zerolog.NewConsoleWriter(func(w *zerolog.ConsoleWriter) {
-		w.TimeFormat = time.RFC3339Nano
-	})).
-		Level(level).
-		With().
-		Timestamp().
-		Logger()
+	writer, err := b.logWriter()
+	if err != nil {
+		return ctx, err
+	}
+
+	// The level is applied globally, so that it can be changed over the control socket while running.
+	zerolog.SetGlobalLevel(level)
+
+	logger := b.newLogger(ctx, writer)
 
 	// Attach the logger to the given context and return the new context.
-	return logger.WithContext(ctx)
+	return logger.WithContext(ctx), nil
+}
+
+// newLogger creates the logger of the messages written to the writer.
+//
+// The agent IDs are taken from the context rather than resolved again,
+// so that the commands which must not generate the agent ID, e.g. doctor, can use any sink.
+func (b *Builder) newLogger(ctx context.Context, writer io.Writer) zerolog.Logger {
+	// Create a new logger with the timestamp of the messages.
+	logContext := zerolog.New(writer).
+		With().
+		Timestamp()
+
+	// Attach the agent IDs and the server to the messages of the structured sinks,
+	// so that the logs can be filtered by them, e.g. "journalctl VAKEEL_ID=<uuid>".
+	if b.config.Log.Sink != logging.SinkStdout {
+		if ids := ctxid.IDs(ctx); len(ids) > 0 {
+			values := make([]string, 0, len(ids))
+			for _, id := range ids {
+				values = append(values, id.String())
+			}
+
+			logContext = logContext.Strs("id", values)
+		}
+
+		logContext = logContext.
+			Str("target", net.JoinHostPort(b.config.Host, strconv.Itoa(b.config.Port)))
+	}
+
+	return logContext.Logger()
+}
+
+// logWriter creates the writer of the configured log sink.
+//
+// The stdout sink writes the messages in the configured format to the standard output,
+// the standard error if requested, or the rotated log file. The syslog and journald sinks format the messages themselves.
+func (b *Builder) logWriter() (io.Writer, error) {
+	if b.config.Log.Sink != logging.SinkStdout && b.config.Log.File != "" {
+		return nil, errLogFileSink
+	}
+
+	switch b.config.Log.Sink {
+	case logging.SinkSyslog:
+		return logging.DialSyslog(logging.SyslogSocket, appName)
+	case logging.SinkJournald:
+		return logging.DialJournal(logging.JournalSocket, appName)
+	case logging.SinkStdout:
+	default:
+		return nil, fmt.Errorf("%w, got %q", errInvalidSink, b.config.Log.Sink)
+	}
+
+	// Write to the standard output unless a log file is configured.
+	var out io.Writer = os.Stdout
+	if b.config.Log.Stderr {
+		out = os.Stderr
+	}
+
+	if b.config.Log.File != "" {
+		file, err := logging.OpenRotatingFile(
+			b.config.Log.File,
+			int64(b.config.Log.MaxSize)*kilobyte,
+			b.config.Log.MaxBackups,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		out = file
+	}
+
+	// Format the messages, colors are only used on terminals.
+	return logging.NewWriter(b.config.Log.Format, out)
+}
+
+// logLevel returns the configured log level.
+//
+// The level is taken from the configuration, then from the LOG_LEVEL environment variable.
+// The default level is used if neither is set.
+func (b *Builder) logLevel() (zerolog.Level, error) {
+	value := b.config.Log.Level
+	if value == "" {
+		value = os.Getenv(levelEnv)
+	}
+
+	if value == "" {
+		return defaultLevel, nil
+	}
+
+	level, err := zerolog.ParseLevel(value)
+	if err != nil || level == zerolog.NoLevel {
+		return defaultLevel, fmt.Errorf("%w, got %q", errInvalidLevel, value)
+	}
+
+	return level, nil
 }
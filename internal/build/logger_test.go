@@ -0,0 +1,57 @@
+package build
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/bavix/vakeel/internal/config"
+	"github.com/bavix/vakeel/internal/infra/logging"
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+func TestNewLoggerKeepsState(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	builder := New(&config.Config{
+		Host:     "way.example.com",
+		Port:     443,
+		StateDir: dir,
+		Log:      config.Log{Sink: logging.SinkJournald},
+	})
+
+	// The doctor creates its logger without the agent IDs.
+	var out bytes.Buffer
+
+	logger := builder.newLogger(context.Background(), &out)
+	logger.Info().Msg("checking")
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Fatalf("state directory has %d entries, want none", len(entries))
+	}
+
+	if line := out.String(); strings.Contains(line, `"id"`) || !strings.Contains(line, `"target":"way.example.com:443"`) {
+		t.Fatalf("logged %s, want the target without the agent IDs", line)
+	}
+
+	// The other commands pass the resolved agent IDs with the context.
+	out.Reset()
+
+	id := uuid.MustParse(testID)
+	logger = builder.newLogger(ctxid.WithIDs(context.Background(), id), &out)
+	logger.Info().Msg("connected")
+
+	if line := out.String(); !strings.Contains(line, `"id":["`+testID+`"]`) {
+		t.Fatalf("logged %s, want the agent ID", line)
+	}
+}
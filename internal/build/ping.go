@@ -0,0 +1,54 @@
+package build
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/rs/zerolog"
+
+	"github.com/bavix/vakeel-way/pkg/api/vakeel_way"
+	"github.com/bavix/vakeel/internal/app"
+	"github.com/bavix/vakeel/internal/config"
+)
+
+// PingApp reports the agent IDs to the server once, with the connection settings of the agent.
+//
+// Parameters:
+//   - ctx: The context of the ping, it provides the logger.
+//   - timeout: The maximum time to wait for the server to acknowledge the update request.
+//
+// Returns:
+//   - An error if the configuration is invalid or the server does not acknowledge the update request.
+func (b *Builder) PingApp(ctx context.Context, timeout time.Duration) error {
+	token, err := b.token()
+	if err != nil {
+		return err
+	}
+
+	identities, err := b.Identities()
+	if err != nil {
+		return err
+	}
+
+	target := net.JoinHostPort(b.config.Host, strconv.Itoa(b.config.Port))
+
+	conn, err := b.connect(ctx, agentSetup{target: target, token: token})
+	if err != nil {
+		return err
+	}
+	defer conn.close()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	client := vakeel_way.NewStateServiceClient(conn.conn)
+	if err := app.Ping(ctx, client, config.UUIDs(identities), labelsOf(identities)); err != nil {
+		return err
+	}
+
+	zerolog.Ctx(ctx).Debug().Str("target", target).Msg("update request acknowledged")
+
+	return nil
+}
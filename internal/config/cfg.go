@@ -1,11 +1,204 @@
 package config
 
+import (
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/bavix/vakeel/pkg/featnix"
+)
+
+// DefaultStateDir returns the default directory where the agent keeps its persistent state.
+//
+// On OpenWrt /var is a tmpfs that is wiped on reboot, so the state is kept in /etc instead.
+func DefaultStateDir() string {
+	if featnix.IsOpenWrt() {
+		return "/etc/vakeel"
+	}
+
+	return "/var/lib/vakeel"
+}
+
+// DefaultControlSocket returns the default path to the control socket of the agent.
+func DefaultControlSocket() string {
+	if featnix.IsOpenWrt() {
+		return "/var/run/vakeel.sock"
+	}
+
+	return "/run/vakeel.sock"
+}
+
+// DefaultHistoryMaxSize returns the default size of the connectivity history in kilobytes.
+//
+// On OpenWrt the state directory is on the flash, which the appends would wear out, so the history is disabled.
+func DefaultHistoryMaxSize() int {
+	if featnix.IsOpenWrt() {
+		return 0
+	}
+
+	return 64 //nolint:mnd
+}
+
+// InstancePath returns the path of a file of the given agent instance, the name of the instance
+// is appended to the file name, e.g. /var/run/vakeel-main.sock for the "main" instance.
+//
+// Parameters:
+//   - path: The path to the file shared by the agents that do not have an instance name.
+//   - instance: The name of the instance, the path is returned as is if it is empty.
+//
+// Returns:
+//   - The path to the file of the instance.
+func InstancePath(path, instance string) string {
+	if instance == "" || path == "" {
+		return path
+	}
+
+	ext := filepath.Ext(path)
+
+	return strings.TrimSuffix(path, ext) + "-" + instance + ext
+}
+
 // Config holds the configuration for the vakeel agent.
 type Config struct {
 	// Host is the host address of the vakeel-way server.
 	Host string
 	// Port is the port number of the vakeel-way server.
 	Port int
-	// ID is the agent ID.
-	ID string
+	// IDs are the agent IDs in the "<uuid>[=<label>]" format.
+	// All of them are reported in every update request.
+	IDs []string
+	// IDSource is the source the agent ID is derived from when no ID is configured.
+	IDSource string
+	// IDNamespace is the namespace of the agent IDs derived from the machine ID.
+	IDNamespace string
+	// StateDir is the directory where the agent keeps its persistent state.
+	StateDir string
+	// Instance is the name of the agent instance, e.g. the UCI section it runs for.
+	// The control socket and the state files of the instance are suffixed with it.
+	Instance string
+	// TLS is the transport security of the connection to the server.
+	TLS TLS
+	// Interval is the duration between update requests sent by the agent.
+	Interval time.Duration
+	// RetryInterval is the minimum delay before reconnecting to the server.
+	RetryInterval time.Duration
+	// Schedules are the windows in which the agent IDs send update requests,
+	// in the "<uuid>=<cron expression>" format.
+	Schedules []string
+	// Phase enables the deterministic offset of update requests derived from the agent ID.
+	Phase bool
+	// Backoff is the reconnection policy of the agent.
+	Backoff Backoff
+	// UCIFile is the path to the UCI configuration file.
+	UCIFile string
+	// UCISection is the reference of the UCI section of the agent, empty if UCI is not used.
+	UCISection string
+	// Sources describes where the configuration came from.
+	Sources Sources
+	// DrainTimeout is the maximum time to wait for the server to acknowledge
+	// the stream close when the agent is stopped.
+	DrainTimeout time.Duration
+	// MetricsListen is the address of the Prometheus metrics endpoint, empty to disable it.
+	MetricsListen string
+	// ControlSocket is the path to the control socket of the agent, empty to disable it.
+	ControlSocket string
+	// OTLP is the export of the traces and the metrics to an OpenTelemetry collector.
+	OTLP OTLP
+	// Hooks are the commands run on the changes of the connection to the server.
+	Hooks Hooks
+	// LED is the status LED of the agent.
+	LED LED
+	// HistoryMaxSize is the size of the connectivity history in kilobytes after which it is rotated,
+	// zero disables the history.
+	HistoryMaxSize int
+	// Log is the logging configuration.
+	Log Log
+}
+
+// OTLP holds the settings of the OpenTelemetry export of the vakeel agent.
+type OTLP struct {
+	// Endpoint is the address of the OTLP gRPC receiver, empty to disable the export.
+	Endpoint string
+	// Insecure disables TLS on the connection to the receiver.
+	Insecure bool
+}
+
+// Hooks holds the commands run on the changes of the connection of the vakeel agent.
+type Hooks struct {
+	// Connect is the command run once a stream to the server has been established.
+	Connect string
+	// Disconnect is the command run once the stream to the server failed.
+	Disconnect string
+	// Outage is the command run once the server has been unreachable for longer than OutageAfter.
+	Outage string
+	// OutageAfter is the time the server must be unreachable before the outage command is run.
+	OutageAfter time.Duration
+	// Timeout is the maximum run time of a command.
+	Timeout time.Duration
+	// MinInterval is the minimum time between two runs of the same command.
+	MinInterval time.Duration
+}
+
+// LED holds the settings of the status LED of the vakeel agent.
+type LED struct {
+	// Name is the name of the LED class device, empty to disable the LED.
+	Name string
+	// Root is the sysfs directory of the LED class devices.
+	Root string
+}
+
+// Log holds the logging configuration of the vakeel commands.
+type Log struct {
+	// Level is the minimum level of the logged messages, e.g. "info".
+	// The LOG_LEVEL environment variable or "info" is used if it is empty.
+	Level string
+	// Format is the format of the log output: console, json or logfmt.
+	Format string
+	// Sink is where the log messages are written: stdout, syslog or journald.
+	// The syslog and journald sinks format the messages themselves.
+	Sink string
+	// File is the path to the log file, the standard output is used if it is empty.
+	File string
+	// MaxSize is the size of the log file in kilobytes after which it is rotated.
+	// Zero disables the rotation.
+	MaxSize int
+	// MaxBackups is the number of rotated log files that are kept.
+	MaxBackups int
+	// Stderr writes the messages to the standard error instead of the standard output.
+	// It is set by the commands that forward the standard output of another command.
+	Stderr bool
+}
+
+// Backoff holds the reconnection policy of the vakeel agent.
+type Backoff struct {
+	// Initial is the delay ceiling of the first reconnection attempt.
+	Initial time.Duration
+	// Max is the upper bound of the delay between reconnection attempts.
+	Max time.Duration
+	// Multiplier is the growth factor of the delay between reconnection attempts.
+	Multiplier float64
+	// ResetAfter is the stream lifetime after which the delay starts over.
+	ResetAfter time.Duration
+}
+
+// TLS holds the transport security settings of the connection to the vakeel-way server.
+type TLS struct {
+	// Plaintext disables TLS. It must be requested explicitly.
+	Plaintext bool
+	// CAFile is the path to a PEM bundle of the CAs trusted to sign the server certificate.
+	CAFile string
+	// ServerName overrides the name used for SNI and to verify the server certificate.
+	ServerName string
+	// Pins are the SPKI SHA-256 pins of the server certificate.
+	Pins []string
+	// CertFile is the path to the PEM-encoded client certificate for mutual TLS.
+	CertFile string
+	// KeyFile is the path to the PEM-encoded private key of the client certificate.
+	KeyFile string
+	// Token is the auth token sent to the server as a bearer token.
+	Token string
+	// TokenFile is the path to the file with the auth token.
+	TokenFile string
+	// CertExpiryWarning is how long before the expiry of the client certificate a warning is logged.
+	CertExpiryWarning time.Duration
 }
@@ -0,0 +1,24 @@
+package config
+
+import "testing"
+
+func TestInstancePath(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		path     string
+		instance string
+		want     string
+	}{
+		{path: "/run/vakeel.sock", instance: "", want: "/run/vakeel.sock"},
+		{path: "/run/vakeel.sock", instance: "main", want: "/run/vakeel-main.sock"},
+		{path: "/run/vakeel", instance: "cfg030f15", want: "/run/vakeel-cfg030f15"},
+		{path: "", instance: "main", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := InstancePath(tt.path, tt.instance); got != tt.want {
+			t.Errorf("InstancePath(%q, %q) = %q, want %q", tt.path, tt.instance, got, tt.want)
+		}
+	}
+}
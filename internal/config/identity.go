@@ -0,0 +1,110 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+// errNoIDs is the error returned when no agent ID is configured.
+var errNoIDs = errors.New("at least one agent ID is required")
+
+// errDuplicateID is the error returned when the same agent ID is configured more than once.
+var errDuplicateID = errors.New("duplicate agent ID")
+
+// errInvalidLabel is the error returned when the label of an agent ID contains unsupported characters.
+var errInvalidLabel = errors.New("label may only contain letters, digits and the characters . _ - @ :")
+
+// labelPattern matches the labels that are accepted for agent IDs.
+//
+// Labels end up in generated service files and command lines, so whitespace
+// and quoting characters are not allowed.
+var labelPattern = regexp.MustCompile(`^[\w.@:-]+$`)
+
+// labelSeparator separates the agent ID from its label, i.e. "<uuid>=<label>".
+const labelSeparator = "="
+
+// Identity is an agent ID with an optional human-readable label.
+type Identity struct {
+	// ID is the agent ID.
+	ID uuid.UUID
+	// Label is the human-readable name of the agent ID used in logs.
+	Label string
+}
+
+// String returns the identity in the "<uuid>[=<label>]" format accepted by ParseIdentity.
+func (i Identity) String() string {
+	if i.Label == "" {
+		return i.ID.String()
+	}
+
+	return i.ID.String() + labelSeparator + i.Label
+}
+
+// ParseIdentity parses an agent ID in the "<uuid>[=<label>]" format.
+//
+// Parameters:
+// - value: The value to parse.
+//
+// Returns:
+// - Identity: The parsed agent ID and its label.
+// - error: An error if the ID or the label is invalid or the ID is the nil UUID.
+func ParseIdentity(value string) (Identity, error) {
+	rawID, label, _ := strings.Cut(strings.TrimSpace(value), labelSeparator)
+
+	id, err := ctxid.Parse(rawID)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	if label != "" && !labelPattern.MatchString(label) {
+		return Identity{}, fmt.Errorf("invalid label %q of agent ID %s: %w", label, id, errInvalidLabel)
+	}
+
+	return Identity{ID: id, Label: label}, nil
+}
+
+// Identities parses the configured agent IDs.
+//
+// Returns:
+// - []Identity: The parsed agent IDs in the configured order.
+// - error: An error if no ID is configured or any of the IDs is invalid or configured more than once.
+func (c *Config) Identities() ([]Identity, error) {
+	if len(c.IDs) == 0 {
+		return nil, errNoIDs
+	}
+
+	identities := make([]Identity, 0, len(c.IDs))
+	seen := make(map[uuid.UUID]struct{}, len(c.IDs))
+
+	for _, value := range c.IDs {
+		identity, err := ParseIdentity(value)
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := seen[identity.ID]; ok {
+			return nil, fmt.Errorf("%w: %s", errDuplicateID, identity.ID)
+		}
+
+		seen[identity.ID] = struct{}{}
+		identities = append(identities, identity)
+	}
+
+	return identities, nil
+}
+
+// UUIDs returns the agent IDs of the given identities.
+func UUIDs(identities []Identity) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(identities))
+	for _, identity := range identities {
+		ids = append(ids, identity.ID)
+	}
+
+	return ids
+}
@@ -0,0 +1,425 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+
+	"github.com/bavix/vakeel/pkg/uci"
+)
+
+// DefaultFile is the configuration file that is read when no file is given explicitly.
+// It is optional, the defaults of the flags are used if it does not exist.
+const DefaultFile = "/etc/vakeel/config.yaml"
+
+// DefaultUCIFile is the UCI configuration file of the agent on OpenWrt.
+const DefaultUCIFile = "/etc/config/vakeel"
+
+// FileEnv is the environment variable with the path to the configuration file.
+const FileEnv = "VAKEEL_CONFIG"
+
+// envPrefix is the prefix of the environment variables that override the configuration file.
+const envPrefix = "VAKEEL_"
+
+// listSeparator separates the values of list settings in environment variables.
+const listSeparator = ","
+
+// localAnnotation is the annotation of the flags that only apply to a single invocation of a command.
+const localAnnotation = "vakeel_local"
+
+// errUnknownKey is the error returned when the configuration file contains an unknown setting.
+var errUnknownKey = errors.New("unknown setting")
+
+// errInvalidValue is the error returned when a setting has a value of an unsupported type.
+var errInvalidValue = errors.New("unsupported value")
+
+// uciAliases maps the UCI options that are not named after flags to the flags they set.
+//
+//nolint:gochecknoglobals
+var uciAliases = map[string]string{
+	"server": "host",
+}
+
+// envIgnored are the settings whose environment variable is not applied to the flag.
+// The agent reads VAKEEL_TOKEN itself, below the token file, see transport.LoadToken.
+//
+//nolint:gochecknoglobals
+var envIgnored = map[string]bool{
+	"token": true,
+}
+
+// uciIgnored are the UCI options that are only used by the init script.
+//
+//nolint:gochecknoglobals
+var uciIgnored = map[string]bool{
+	"enabled": true,
+}
+
+// Files holds the locations of the configuration files.
+type Files struct {
+	// Config is the path to the YAML configuration file.
+	// DefaultFile is read if it is empty and exists.
+	Config string
+	// UCI is the path to the UCI configuration file.
+	UCI string
+	// UCISection is the reference of the UCI section of the agent, e.g. "main" or "@agent[0]".
+	// The UCI configuration is not read if it is empty.
+	UCISection string
+}
+
+// Sources describes where the configuration of a command came from.
+type Sources struct {
+	// File is the path to the configuration file that was read, empty if none was read.
+	File string
+	// UCI is the path to the UCI configuration file that was read, empty if none was read.
+	UCI string
+	// UCISection is the reference of the UCI section that was read.
+	UCISection string
+	// Args are the flags that were set on the command line, in the "--name=value" format.
+	Args []string
+}
+
+// Load applies the configuration files and the environment to the flags.
+//
+// The layers take precedence in the following order, from lowest to highest:
+// the defaults of the flags, the configuration file, the UCI section, the
+// VAKEEL_* environment variables and the flags set on the command line.
+//
+// The settings of the configuration file and the environment variables are named
+// after the flags, e.g. the "backoff-initial" setting of the file or the
+// VAKEEL_BACKOFF_INITIAL environment variable sets the --backoff-initial flag.
+// Nested mappings of the file are joined with "-", so "backoff: {initial: 1s}"
+// is the same setting. Values of list flags are YAML sequences in the file and
+// comma-separated in environment variables. VAKEEL_TOKEN does not set the --token
+// flag, the token is loaded from it below the --token-file flag.
+//
+// The options of the UCI section are named after the flags as well, with "_"
+// instead of "-". The "server" option sets the host. Durations without a unit
+// are seconds, e.g. "option interval '30'".
+//
+// Parameters:
+//   - flags: The flags of the command.
+//   - files: The locations of the configuration files.
+//   - known: Reports whether a setting is known to any command. Settings known to other
+//     commands are ignored, so the same file can be shared between the commands.
+//
+// Returns:
+//   - Sources: Where the configuration came from.
+//   - error: An error if the file cannot be read or a setting is unknown or invalid.
+func Load(flags *pflag.FlagSet, files Files, known func(name string) bool) (Sources, error) {
+	// Remember the flags set on the command line before the other layers mark theirs as changed.
+	sources := Sources{Args: changedArgs(flags)}
+
+	// Apply the environment variables to the flags that were not set on the command line.
+	if err := loadEnv(flags); err != nil {
+		return sources, err
+	}
+
+	// Apply the UCI section to the flags that were set neither on the command line nor in the environment.
+	if files.UCISection != "" {
+		if err := loadUCI(flags, files.UCI, files.UCISection, known); err != nil {
+			return sources, err
+		}
+
+		sources.UCI, sources.UCISection = files.UCI, files.UCISection
+	}
+
+	// Fall back to the default file, which does not have to exist.
+	path := files.Config
+	optional := false
+	if path == "" {
+		path, optional = DefaultFile, true
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if optional && errors.Is(err, os.ErrNotExist) {
+			return sources, nil
+		}
+
+		return sources, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// Apply the file to the flags that are not set by the higher layers.
+	if err := loadFile(flags, path, content, known); err != nil {
+		return sources, err
+	}
+
+	// Keep the absolute path, the generated service files refer to it.
+	if sources.File, err = filepath.Abs(path); err != nil {
+		return sources, err
+	}
+
+	return sources, nil
+}
+
+// loadEnv applies the VAKEEL_* environment variables to the flags that were not set on the command line.
+func loadEnv(flags *pflag.FlagSet) error {
+	var errs []error
+
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if flag.Changed || skipFlag(flag.Name) || IsLocal(flag) || envIgnored[flag.Name] {
+			return
+		}
+
+		name := EnvName(flag.Name)
+
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+
+		values := []string{value}
+		if _, isList := flag.Value.(pflag.SliceValue); isList {
+			values = strings.Split(value, listSeparator)
+		}
+
+		if err := setFlag(flags, flag, values); err != nil {
+			errs = append(errs, fmt.Errorf("invalid value of %s: %w", name, err))
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// loadFile applies the YAML configuration file to the flags that are not set yet.
+func loadFile(flags *pflag.FlagSet, path string, content []byte, known func(name string) bool) error {
+	var document map[string]any
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	settings := make(map[string][]string)
+	if err := flatten(settings, "", document); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	return applySettings(flags, "config file "+path, settings, known)
+}
+
+// loadUCI applies the options of the UCI section to the flags that are not set yet.
+func loadUCI(flags *pflag.FlagSet, path, ref string, known func(name string) bool) error {
+	sections, err := uci.ParseFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read UCI config: %w", err)
+	}
+
+	section, err := uci.Find(sections, ref)
+	if err != nil {
+		return fmt.Errorf("failed to read UCI config %s: %w", path, err)
+	}
+
+	settings := make(map[string][]string, len(section.Options))
+
+	for option, values := range section.Options {
+		if uciIgnored[option] {
+			continue
+		}
+
+		name := strings.ReplaceAll(option, "_", "-")
+		if alias, ok := uciAliases[name]; ok {
+			name = alias
+		}
+
+		// Durations without a unit are seconds, as usual in UCI configurations.
+		if flag := flags.Lookup(name); flag != nil && flag.Value.Type() == "duration" {
+			values = withSeconds(values)
+		}
+
+		settings[name] = values
+	}
+
+	return applySettings(flags, "UCI section "+ref+" of "+path, settings, known)
+}
+
+// withSeconds appends the "s" unit to the values that are plain numbers.
+func withSeconds(values []string) []string {
+	result := make([]string, 0, len(values))
+
+	for _, value := range values {
+		if _, err := strconv.ParseFloat(value, 64); err == nil {
+			value += "s"
+		}
+
+		result = append(result, value)
+	}
+
+	return result
+}
+
+// applySettings applies the settings to the flags that are not set yet.
+//
+// Parameters:
+//   - flags: The flags of the command.
+//   - source: The description of the source used in errors.
+//   - settings: The values of the settings by flag name.
+//   - known: Reports whether a setting is known to any command.
+//
+// Returns:
+//   - An error if a setting is unknown or invalid.
+func applySettings(
+	flags *pflag.FlagSet,
+	source string,
+	settings map[string][]string,
+	known func(name string) bool,
+) error {
+	// Apply the settings in a stable order, so that errors are reported deterministically.
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if skipFlag(name) || !known(name) {
+			return fmt.Errorf("invalid %s: %w %q", source, errUnknownKey, name)
+		}
+
+		// The setting belongs to another command, has been set by a higher layer
+		// or is a flag of this command that is only set on the command line.
+		flag := flags.Lookup(name)
+		if flag == nil || flag.Changed || IsLocal(flag) {
+			continue
+		}
+
+		if err := setFlag(flags, flag, settings[name]); err != nil {
+			return fmt.Errorf("invalid value of %q in %s: %w", name, source, err)
+		}
+	}
+
+	return nil
+}
+
+// flatten collects the settings of the YAML mapping, joining the keys of nested mappings with "-".
+func flatten(settings map[string][]string, prefix string, document map[string]any) error {
+	for key, value := range document {
+		name := key
+		if prefix != "" {
+			name = prefix + "-" + key
+		}
+
+		switch value := value.(type) {
+		case map[string]any:
+			if err := flatten(settings, name, value); err != nil {
+				return err
+			}
+		case []any:
+			values := make([]string, 0, len(value))
+
+			for _, item := range value {
+				scalar, err := scalarString(item)
+				if err != nil {
+					return fmt.Errorf("%q: %w", name, err)
+				}
+
+				values = append(values, scalar)
+			}
+
+			settings[name] = values
+		default:
+			scalar, err := scalarString(value)
+			if err != nil {
+				return fmt.Errorf("%q: %w", name, err)
+			}
+
+			settings[name] = []string{scalar}
+		}
+	}
+
+	return nil
+}
+
+// scalarString returns the string representation of a YAML scalar.
+func scalarString(value any) (string, error) {
+	switch value := value.(type) {
+	case string:
+		return value, nil
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(value), nil
+	default:
+		return "", fmt.Errorf("%w %T", errInvalidValue, value)
+	}
+}
+
+// setFlag sets the flag to the given values and marks it as changed.
+//
+// List flags are replaced with all the values, other flags are set to the last value.
+func setFlag(flags *pflag.FlagSet, flag *pflag.Flag, values []string) error {
+	if list, ok := flag.Value.(pflag.SliceValue); ok {
+		if err := list.Replace(values); err != nil {
+			return err
+		}
+
+		flag.Changed = true
+
+		return nil
+	}
+
+	if len(values) == 0 {
+		return nil
+	}
+
+	return flags.Set(flag.Name, values[len(values)-1])
+}
+
+// changedArgs returns the flags set on the command line in the "--name=value" format.
+func changedArgs(flags *pflag.FlagSet) []string {
+	var args []string
+
+	flags.Visit(func(flag *pflag.Flag) {
+		if skipFlag(flag.Name) {
+			return
+		}
+
+		if list, ok := flag.Value.(pflag.SliceValue); ok {
+			for _, value := range list.GetSlice() {
+				args = append(args, "--"+flag.Name+"="+value)
+			}
+
+			return
+		}
+
+		args = append(args, "--"+flag.Name+"="+flag.Value.String())
+	})
+
+	return args
+}
+
+// skipFlag reports whether the flag is not a setting, i.e. it cannot be set in the file or the environment.
+func skipFlag(name string) bool {
+	switch name {
+	case "config", "uci-file", "uci-section", "help":
+		return true
+	default:
+		return false
+	}
+}
+
+// MarkLocal marks the flags that only apply to a single invocation of the command, e.g. the IDs to pause.
+// They are only set on the command line, the settings of the same name in the configuration files
+// and the environment are meant for the other commands.
+func MarkLocal(flags *pflag.FlagSet, names ...string) {
+	for _, name := range names {
+		_ = flags.SetAnnotation(name, localAnnotation, []string{"true"})
+	}
+}
+
+// IsLocal reports whether the flag only applies to a single invocation of the command.
+func IsLocal(flag *pflag.Flag) bool {
+	_, ok := flag.Annotations[localAnnotation]
+
+	return ok
+}
+
+// EnvName returns the name of the environment variable that sets the flag, e.g. VAKEEL_BACKOFF_INITIAL.
+func EnvName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// testFlags holds the values of the flags of the loader tests.
+type testFlags struct {
+	host     string
+	port     int
+	interval time.Duration
+	initial  time.Duration
+	ids      []string
+	pause    time.Duration
+	token    string
+}
+
+// newTestFlags defines flags of every kind a command has, including a local one.
+func newTestFlags(values *testFlags) *pflag.FlagSet {
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	flags.StringVar(&values.host, "host", "127.0.0.1", "")
+	flags.IntVar(&values.port, "port", 4643, "")
+	flags.DurationVar(&values.interval, "interval", 5*time.Second, "")
+	flags.DurationVar(&values.initial, "backoff-initial", time.Second, "")
+	flags.StringArrayVar(&values.ids, "id", nil, "")
+	flags.DurationVar(&values.pause, "for", 0, "")
+	flags.StringVar(&values.token, "token", "", "")
+	flags.String("token-file", "", "")
+	flags.String("uci-section", "", "")
+
+	MarkLocal(flags, "for")
+
+	return flags
+}
+
+// writeFile writes the content to a file in a temporary directory and returns its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	// The environment variables are set per test, so the tests do not run in parallel.
+	tests := []struct {
+		name    string
+		file    string
+		uci     string
+		env     map[string]string
+		args    []string
+		want    testFlags
+		wantErr error
+	}{
+		{
+			name: "defaults",
+			want: testFlags{host: "127.0.0.1", port: 4643, interval: 5 * time.Second, initial: time.Second},
+		},
+		{
+			name: "file",
+			file: "host: vakeel.example.com\nport: 443\ninterval: 1m\nbackoff:\n  initial: 2s\nid: [a, b]\n",
+			want: testFlags{
+				host: "vakeel.example.com", port: 443, interval: time.Minute, initial: 2 * time.Second,
+				ids: []string{"a", "b"},
+			},
+		},
+		{
+			name: "UCI over file",
+			file: "host: file.example.com\nport: 443\ninterval: 1m\n",
+			uci: "config agent 'main'\n\toption enabled '1'\n\toption server 'uci.example.com'\n" +
+				"\toption interval '30'\n\toption backoff_initial '3'\n\tlist id 'c'\n",
+			want: testFlags{
+				host: "uci.example.com", port: 443, interval: 30 * time.Second, initial: 3 * time.Second,
+				ids: []string{"c"},
+			},
+		},
+		{
+			name: "environment over UCI",
+			file: "port: 443\n",
+			uci:  "config agent 'main'\n\toption server 'uci.example.com'\n\toption interval '30'\n",
+			env:  map[string]string{"VAKEEL_HOST": "env.example.com", "VAKEEL_ID": "d,e"},
+			want: testFlags{
+				host: "env.example.com", port: 443, interval: 30 * time.Second, initial: time.Second,
+				ids: []string{"d", "e"},
+			},
+		},
+		{
+			name: "flags over environment",
+			file: "host: file.example.com\nid: [a]\n",
+			env:  map[string]string{"VAKEEL_HOST": "env.example.com", "VAKEEL_PORT": "8443"},
+			args: []string{"--host=flag.example.com", "--id=f"},
+			want: testFlags{
+				host: "flag.example.com", port: 8443, interval: 5 * time.Second, initial: time.Second,
+				ids: []string{"f"},
+			},
+		},
+		{
+			name:    "local flags are not settings",
+			file:    "for: 1h\n",
+			wantErr: errUnknownKey,
+		},
+		{
+			name: "local flags are not set by the environment",
+			env:  map[string]string{"VAKEEL_FOR": "2h"},
+			want: testFlags{host: "127.0.0.1", port: 4643, interval: 5 * time.Second, initial: time.Second},
+		},
+		{
+			name: "token is not set by the environment",
+			env:  map[string]string{"VAKEEL_TOKEN": "secret"},
+			args: []string{"--token-file=/etc/vakeel/token"},
+			want: testFlags{host: "127.0.0.1", port: 4643, interval: 5 * time.Second, initial: time.Second},
+		},
+		{
+			name:    "unknown setting",
+			file:    "hots: vakeel.example.com\n",
+			wantErr: errUnknownKey,
+		},
+		{
+			name:    "file settings are not flags",
+			file:    "uci-section: main\n",
+			wantErr: errUnknownKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				t.Setenv(name, value)
+			}
+
+			var got testFlags
+
+			flags := newTestFlags(&got)
+			if err := flags.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			known := func(name string) bool {
+				flag := flags.Lookup(name)
+
+				return flag != nil && !IsLocal(flag)
+			}
+
+			// The file is always given, so that the default file of the host is not read.
+			files := Files{Config: writeFile(t, "config.yaml", tt.file)}
+
+			if tt.uci != "" {
+				files.UCI, files.UCISection = writeFile(t, "vakeel", tt.uci), "@agent[0]"
+			}
+
+			_, err := Load(flags, files, known)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil {
+				return
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Load() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadSources(t *testing.T) {
+	var values testFlags
+
+	flags := newTestFlags(&values)
+	if err := flags.Parse([]string{"--host=flag.example.com", "--id=a", "--id=b", "--uci-section=main"}); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeFile(t, "config.yaml", "port: 443\n")
+
+	sources, err := Load(flags, Files{Config: path}, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the flags of the command line are recorded, not the ones set by the file.
+	want := Sources{File: path, Args: []string{"--host=flag.example.com", "--id=a", "--id=b"}}
+	if !reflect.DeepEqual(sources, want) {
+		t.Fatalf("Load() = %+v, want %+v", sources, want)
+	}
+}
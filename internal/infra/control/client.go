@@ -0,0 +1,123 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// baseURL is the URL of the control API, the host is ignored since the client dials the socket.
+const baseURL = "http://vakeel"
+
+// errUnexpectedResponse is the error returned when the control API responds without an error message.
+var errUnexpectedResponse = errors.New("unexpected response from the agent")
+
+// Client talks to a running agent over its control socket.
+type Client struct {
+	// path is the path to the control socket.
+	path string
+	// http is the HTTP client that dials the control socket.
+	http *http.Client
+}
+
+// NewClient creates a client of the control socket at the given path.
+//
+// Parameters:
+// - path: The path to the control socket.
+//
+// Returns:
+// - *Client: A pointer to the Client instance.
+func NewClient(path string) *Client {
+	var dialer net.Dialer
+
+	return &Client{
+		path: path,
+		http: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					return dialer.DialContext(ctx, "unix", path)
+				},
+			},
+		},
+	}
+}
+
+// Status returns the status of the agent.
+func (c *Client) Status(ctx context.Context) (Status, error) {
+	var status Status
+
+	return status, c.do(ctx, http.MethodGet, PathStatus, nil, &status)
+}
+
+// SetLogLevel changes the log level of the agent and returns its new status.
+func (c *Client) SetLogLevel(ctx context.Context, level string) (Status, error) {
+	var status Status
+
+	return status, c.do(ctx, http.MethodPut, PathLogLevel, LogLevel{Level: level}, &status)
+}
+
+// Pause pauses the update requests of the given agent IDs, or of all of them if none is given,
+// until the given time and returns the new status of the agent.
+func (c *Client) Pause(ctx context.Context, ids []string, until time.Time) (Status, error) {
+	var status Status
+
+	return status, c.do(ctx, http.MethodPost, PathPause, PauseRequest{IDs: ids, Until: until}, &status)
+}
+
+// Resume resumes the update requests of the given agent IDs, or of all of them if none is given,
+// and returns the new status of the agent.
+func (c *Client) Resume(ctx context.Context, ids []string) (Status, error) {
+	var status Status
+
+	return status, c.do(ctx, http.MethodPost, PathResume, ResumeRequest{IDs: ids}, &status)
+}
+
+// do sends the request with the JSON body to the control API and decodes the JSON response.
+//
+// Parameters:
+// - ctx: The context of the request.
+// - method: The HTTP method.
+// - path: The path of the control API.
+// - body: The value sent as the JSON body, nil for no body.
+// - result: The value the response is decoded into.
+//
+// Returns:
+// - error: An error if the agent cannot be reached or the request fails.
+func (c *Client) do(ctx context.Context, method, path string, body, result any) error {
+	var payload bytes.Buffer
+
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			return err
+		}
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, baseURL+path, &payload)
+	if err != nil {
+		return err
+	}
+
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := c.http.Do(request)
+	if err != nil {
+		return fmt.Errorf("failed to reach the agent on %s, is it running? %w", c.path, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		var failure apiError
+		if err := json.NewDecoder(response.Body).Decode(&failure); err != nil || failure.Error == "" {
+			return fmt.Errorf("%w: %s", errUnexpectedResponse, response.Status)
+		}
+
+		return errors.New(failure.Error) //nolint:err113
+	}
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
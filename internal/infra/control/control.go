@@ -0,0 +1,105 @@
+// Package control implements the local control socket of the agent.
+//
+// The agent serves a small JSON API over HTTP on a Unix domain socket, so that
+// the local commands can query its state and control it without reading the logs.
+package control
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// Paths of the control API.
+const (
+	// PathStatus returns the status of the agent.
+	PathStatus = "/v1/status"
+	// PathLogLevel changes the log level of the agent.
+	PathLogLevel = "/v1/log-level"
+	// PathPause pauses the update requests.
+	PathPause = "/v1/pause"
+	// PathResume resumes the update requests.
+	PathResume = "/v1/resume"
+)
+
+// ErrAgentRunning is the error returned when another agent already serves the control socket.
+var ErrAgentRunning = errors.New("another agent is listening on the control socket")
+
+// Controller is the running agent as seen by the control socket.
+type Controller interface {
+	// Status returns the current status of the agent.
+	Status(ctx context.Context) Status
+	// SetLogLevel changes the minimum level of the logged messages, e.g. "debug".
+	SetLogLevel(ctx context.Context, level string) error
+	// Pause stops the update requests of the given agent IDs, or of all of them if none is given,
+	// until the given time.
+	Pause(ctx context.Context, ids []string, until time.Time) error
+	// Resume sends the update requests of the given agent IDs again, or of all of them if none is given.
+	Resume(ctx context.Context, ids []string) error
+}
+
+// Status is the state of the agent returned by the control socket.
+type Status struct {
+	// Version is the version of the agent.
+	Version string `json:"version"`
+	// PID is the process ID of the agent.
+	PID int `json:"pid"`
+	// State is the connection state, e.g. "connected" or "reconnecting".
+	State string `json:"state"`
+	// Since is the time the connection state was entered.
+	Since time.Time `json:"since"`
+	// LastSend is the time of the last successful update request, nil before the first one.
+	LastSend *time.Time `json:"last_send,omitempty"`
+	// LastError is the last error of a stream open or an update request.
+	LastError string `json:"last_error,omitempty"`
+	// BackoffSeconds is the delay before the next reconnection attempt, zero unless reconnecting.
+	BackoffSeconds float64 `json:"backoff_seconds,omitempty"`
+	// IDs are the agent IDs sent in every update request.
+	IDs []ID `json:"ids"`
+	// Pauses are the pauses of the update requests in effect.
+	Pauses []Pause `json:"pauses"`
+	// LogLevel is the minimum level of the logged messages.
+	LogLevel string `json:"log_level"`
+}
+
+// ID is an agent ID with its label.
+type ID struct {
+	// ID is the UUID of the agent.
+	ID string `json:"id"`
+	// Label is the human-readable name of the agent ID, if it has one.
+	Label string `json:"label,omitempty"`
+}
+
+// Pause is a pause of the update requests of an agent ID.
+type Pause struct {
+	// ID is the paused agent ID, empty if all the agent IDs are paused.
+	ID string `json:"id,omitempty"`
+	// Until is the time the update requests are sent again.
+	Until time.Time `json:"until"`
+}
+
+// PauseRequest is the body of the pause request.
+type PauseRequest struct {
+	// IDs are the agent IDs to pause, all of them if it is empty.
+	IDs []string `json:"ids,omitempty"`
+	// Until is the time the update requests are sent again.
+	Until time.Time `json:"until"`
+}
+
+// ResumeRequest is the body of the resume request.
+type ResumeRequest struct {
+	// IDs are the agent IDs to resume, all of them if it is empty.
+	IDs []string `json:"ids,omitempty"`
+}
+
+// LogLevel is the body of the log level request.
+type LogLevel struct {
+	// Level is the minimum level of the logged messages, e.g. "debug".
+	Level string `json:"level"`
+}
+
+// apiError is the body of a failed request.
+type apiError struct {
+	// Error is the message of the error.
+	Error string `json:"error"`
+}
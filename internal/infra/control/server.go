@@ -0,0 +1,167 @@
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// socketMode is the file mode of the control socket, only root and its group may control the agent.
+const socketMode = 0o660
+
+// shutdownTimeout is the maximum time to wait for the pending requests when the server stops.
+const shutdownTimeout = 5 * time.Second
+
+// readHeaderTimeout limits the time to read the request headers.
+const readHeaderTimeout = 10 * time.Second
+
+// Listen creates the control socket at the given path.
+//
+// A stale socket left by an agent that did not stop gracefully is replaced,
+// while a socket that still accepts connections is kept and ErrAgentRunning is returned.
+//
+// Parameters:
+// - path: The path to the control socket.
+//
+// Returns:
+// - net.Listener: The listener of the control socket, the socket is removed when it is closed.
+// - error: An error if the socket cannot be created.
+func Listen(path string) (net.Listener, error) {
+	if _, err := os.Lstat(path); err == nil {
+		if conn, err := net.Dial("unix", path); err == nil {
+			_ = conn.Close()
+
+			return nil, fmt.Errorf("%w: %s", ErrAgentRunning, path)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove stale control socket: %w", err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create control socket: %w", err)
+	}
+
+	if err := os.Chmod(path, socketMode); err != nil {
+		_ = listener.Close()
+
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// Serve serves the control API on the listener until the context is cancelled.
+//
+// Parameters:
+// - ctx: The context that stops the server.
+// - listener: The listener of the control socket.
+// - controller: The running agent.
+//
+// Returns:
+// - error: An error if the server fails, nil once it has been stopped.
+func Serve(ctx context.Context, listener net.Listener, controller Controller) error {
+	server := &http.Server{
+		Handler:           Handler(controller),
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	// Stop the server once the context is cancelled.
+	stopped := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(stopped)
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	})
+
+	err := server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		<-stopped
+
+		return nil
+	}
+
+	stop()
+
+	return err
+}
+
+// Handler returns the HTTP handler of the control API.
+//
+// Parameters:
+// - controller: The running agent.
+//
+// Returns:
+// - http.Handler: The handler of the control API.
+func Handler(controller Controller) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET "+PathStatus, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, controller.Status(r.Context()))
+	})
+
+	mux.HandleFunc("PUT "+PathLogLevel, command(controller, func(ctx context.Context, body LogLevel) error {
+		return controller.SetLogLevel(ctx, body.Level)
+	}))
+
+	mux.HandleFunc("POST "+PathPause, command(controller, func(ctx context.Context, body PauseRequest) error {
+		return controller.Pause(ctx, body.IDs, body.Until)
+	}))
+
+	mux.HandleFunc("POST "+PathResume, command(controller, func(ctx context.Context, body ResumeRequest) error {
+		return controller.Resume(ctx, body.IDs)
+	}))
+
+	// Answer the unknown commands in JSON as well, so that the client reports them.
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusNotFound, apiError{Error: fmt.Sprintf("unknown command: %s %s", r.Method, r.URL.Path)})
+	})
+
+	return mux
+}
+
+// command returns the handler of a request that changes the agent.
+//
+// The JSON body of the request is decoded and passed to the action, an empty body is the zero value.
+// The new status of the agent is returned if the action succeeds, otherwise the error.
+func command[T any](controller Controller, action func(ctx context.Context, body T) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var body T
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+
+			return
+		}
+
+		if err := action(r.Context(), body); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{Error: err.Error()})
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, controller.Status(r.Context()))
+	}
+}
+
+// writeJSON writes the value as the JSON body of the response with the given status code.
+func writeJSON(w http.ResponseWriter, code int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+
+	_ = json.NewEncoder(w).Encode(value)
+}
@@ -0,0 +1,229 @@
+//go:build unix
+
+package control
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// errLevel is the error of the test controller for an unknown log level.
+var errLevel = errors.New("unknown log level")
+
+// testController is a Controller that records the commands it receives.
+type testController struct {
+	mu     sync.Mutex
+	level  string
+	pauses []Pause
+}
+
+func (c *testController) Status(context.Context) Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return Status{Version: "test", State: "connected", LogLevel: c.level, Pauses: slices.Clone(c.pauses)}
+}
+
+func (c *testController) SetLogLevel(_ context.Context, level string) error {
+	if level != "debug" && level != "info" {
+		return errLevel
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.level = level
+
+	return nil
+}
+
+func (c *testController) Pause(_ context.Context, ids []string, until time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(ids) == 0 {
+		c.pauses = append(c.pauses, Pause{Until: until})
+	}
+
+	for _, id := range ids {
+		c.pauses = append(c.pauses, Pause{ID: id, Until: until})
+	}
+
+	return nil
+}
+
+func (c *testController) Resume(_ context.Context, ids []string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.pauses = slices.DeleteFunc(c.pauses, func(pause Pause) bool {
+		return len(ids) == 0 || slices.Contains(ids, pause.ID)
+	})
+
+	return nil
+}
+
+// startServer serves the test controller on a control socket in a temporary directory.
+func startServer(t *testing.T) (*Client, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- Serve(ctx, listener, &testController{level: "info"}) }()
+
+	t.Cleanup(func() {
+		cancel()
+
+		if err := <-done; err != nil {
+			t.Errorf("Serve() = %v, want nil", err)
+		}
+	})
+
+	return NewClient(path), path
+}
+
+func TestServe(t *testing.T) {
+	t.Parallel()
+
+	client, _ := startServer(t)
+	ctx := context.Background()
+	until := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	status, err := client.Status(ctx)
+	if err != nil || status.Version != "test" || status.LogLevel != "info" {
+		t.Fatalf("Status() = %+v, %v, want the test status", status, err)
+	}
+
+	if status, err = client.SetLogLevel(ctx, "debug"); err != nil || status.LogLevel != "debug" {
+		t.Fatalf("SetLogLevel() = %+v, %v, want the debug level", status, err)
+	}
+
+	if _, err = client.SetLogLevel(ctx, "loud"); err == nil || err.Error() != errLevel.Error() {
+		t.Fatalf("SetLogLevel() = %v, want %v", err, errLevel)
+	}
+
+	status, err = client.Pause(ctx, []string{"a", "b"}, until)
+	if err != nil || len(status.Pauses) != 2 || status.Pauses[1].ID != "b" || !status.Pauses[1].Until.Equal(until) {
+		t.Fatalf("Pause() = %+v, %v, want the pauses of a and b", status.Pauses, err)
+	}
+
+	if status, err = client.Resume(ctx, []string{"a"}); err != nil || len(status.Pauses) != 1 || status.Pauses[0].ID != "b" {
+		t.Fatalf("Resume() = %+v, %v, want the pause of b", status.Pauses, err)
+	}
+
+	if status, err = client.Resume(ctx, nil); err != nil || len(status.Pauses) != 0 {
+		t.Fatalf("Resume() = %+v, %v, want no pause", status.Pauses, err)
+	}
+}
+
+func TestServeErrors(t *testing.T) {
+	t.Parallel()
+
+	client, _ := startServer(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		want   string
+	}{
+		{name: "unknown command", method: "POST", path: "/v1/restart", want: "unknown command: POST /v1/restart"},
+		{name: "wrong method", method: "GET", path: PathPause, want: "unknown command: GET /v1/pause"},
+		{name: "invalid body", method: "PUT", path: PathLogLevel, body: []string{"debug"}, want: "cannot unmarshal"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			var status Status
+			if err := client.do(ctx, test.method, test.path, test.body, &status); err == nil ||
+				!strings.Contains(err.Error(), test.want) {
+				t.Fatalf("do() = %v, want an error containing %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestListen(t *testing.T) {
+	t.Parallel()
+
+	_, path := startServer(t)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Type() != fs.ModeSocket || info.Mode().Perm() != socketMode {
+		t.Fatalf("mode = %v, want a socket with %v", info.Mode(), fs.FileMode(socketMode))
+	}
+
+	if _, err := Listen(path); !errors.Is(err, ErrAgentRunning) {
+		t.Fatalf("Listen() = %v, want %v", err, ErrAgentRunning)
+	}
+}
+
+func TestListenStale(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	// A socket file nobody listens on anymore.
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatalf("Listen() = %v, want the stale socket to be replaced", err)
+	}
+
+	if err := listener.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Lstat() = %v, want the socket to be removed on close", err)
+	}
+}
+
+func TestServeRemovesSocket(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "control.sock")
+
+	listener, err := Listen(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := Serve(ctx, listener, &testController{}); err != nil {
+		t.Fatalf("Serve() = %v, want nil", err)
+	}
+
+	if _, err := os.Lstat(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("Lstat() = %v, want the socket to be removed once the server stops", err)
+	}
+}
@@ -0,0 +1,193 @@
+// Package history keeps the journal of the connectivity of the agent, so that the outages
+// can be reviewed on the device itself once the server is reachable again.
+package history
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fileName is the name of the journal in the state directory.
+const fileName = "history.log"
+
+// fileMode is the file mode of the journal.
+const fileMode = 0o644
+
+// timeFormat is the format of the times of the records, RFC 3339 in UTC with milliseconds.
+const timeFormat = "2006-01-02T15:04:05.000Z07:00"
+
+// Kind is the kind of a record of the journal.
+type Kind string
+
+const (
+	// KindStart is the record of an agent that started, it is not connected yet.
+	KindStart Kind = "start"
+	// KindUp is the record of a stream to the server that has been established.
+	KindUp Kind = "up"
+	// KindDown is the record of a stream to the server that failed or could not be opened.
+	KindDown Kind = "down"
+	// KindStop is the record of an agent that stopped, its connectivity is unknown until it starts again.
+	KindStop Kind = "stop"
+)
+
+// Record is a change of the connectivity of the agent.
+type Record struct {
+	// At is the time of the change.
+	At time.Time
+	// Kind is the kind of the change.
+	Kind Kind
+	// Code is the gRPC code of the error of a down record, empty if there was none.
+	Code string
+}
+
+// String formats the record as a line of the journal, e.g. "2026-10-16T20:52:14.123Z down Unavailable".
+func (r Record) String() string {
+	line := r.At.UTC().Format(timeFormat) + " " + string(r.Kind)
+	if r.Code != "" {
+		line += " " + r.Code
+	}
+
+	return line
+}
+
+// Journal is the size-bounded journal of the connectivity of the agent.
+//
+// The records are appended to a text file with one record per line. Once the file exceeds
+// the maximum size it is moved to "<path>.1", replacing the previous one, so that the journal
+// never takes more than twice the maximum size.
+type Journal struct {
+	// path is the path to the journal.
+	path string
+	// maxSize is the size in bytes after which the journal is rotated.
+	maxSize int64
+	// mu serializes the appends.
+	mu sync.Mutex
+}
+
+// NewJournal creates the journal in the given state directory.
+//
+// Parameters:
+// - stateDir: The directory where the agent keeps its persistent state.
+// - instance: The name of the agent instance, it is appended to the file name, e.g. "history-main.log".
+// - maxSize: The size in bytes after which the journal is rotated.
+//
+// Returns:
+// - *Journal: A pointer to the Journal instance.
+func NewJournal(stateDir, instance string, maxSize int64) *Journal {
+	name := fileName
+	if instance != "" {
+		name = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "-" + instance + filepath.Ext(fileName)
+	}
+
+	return &Journal{path: filepath.Join(stateDir, name), maxSize: maxSize}
+}
+
+// Path returns the path to the journal.
+func (j *Journal) Path() string {
+	return j.path
+}
+
+// Append appends the record to the journal, the journal is rotated first if it is full.
+//
+// Parameters:
+// - record: The record to append.
+//
+// Returns:
+// - error: An error if the record cannot be written.
+func (j *Journal) Append(record Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if info, err := os.Stat(j.path); err == nil && info.Size() >= j.maxSize {
+		if err := os.Rename(j.path, j.backup()); err != nil {
+			return fmt.Errorf("failed to rotate the history: %w", err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(j.path), 0o755); err != nil { //nolint:mnd
+		return err
+	}
+
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, fileMode)
+	if err != nil {
+		return err
+	}
+
+	_, err = file.WriteString(record.String() + "\n")
+
+	return errors.Join(err, file.Close())
+}
+
+// Records reads the records of the journal, the oldest first.
+// The lines that cannot be parsed, e.g. a line cut short by a power loss, are skipped.
+//
+// Returns:
+// - []Record: The records of the journal, none if it does not exist.
+// - error: An error if the journal cannot be read.
+func (j *Journal) Records() ([]Record, error) {
+	var records []Record
+
+	for _, path := range []string{j.backup(), j.path} {
+		file, err := os.Open(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			if record, ok := parse(scanner.Text()); ok {
+				records = append(records, record)
+			}
+		}
+
+		err = errors.Join(scanner.Err(), file.Close())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read the history %s: %w", path, err)
+		}
+	}
+
+	return records, nil
+}
+
+// backup returns the path to the rotated journal.
+func (j *Journal) backup() string {
+	return j.path + ".1"
+}
+
+// parse parses a line of the journal.
+func parse(line string) (Record, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 { //nolint:mnd
+		return Record{}, false
+	}
+
+	at, err := time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return Record{}, false
+	}
+
+	record := Record{At: at, Kind: Kind(fields[1])}
+
+	switch record.Kind {
+	case KindStart, KindUp, KindDown, KindStop:
+	default:
+		return Record{}, false
+	}
+
+	if len(fields) == 3 { //nolint:mnd
+		record.Code = fields[2]
+	}
+
+	return record, true
+}
@@ -0,0 +1,70 @@
+package history
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	at := time.Date(2026, 10, 16, 20, 52, 14, 123_000_000, time.UTC)
+	record := Record{At: at, Kind: KindDown, Code: "Unavailable"}
+
+	got, ok := parse(record.String())
+	if !ok || !got.At.Equal(at) || got.Kind != KindDown || got.Code != "Unavailable" {
+		t.Fatalf("parse(%q) = %+v, %v", record.String(), got, ok)
+	}
+
+	for _, line := range []string{"", "2026-10-16T20:52", "yesterday up", "2026-10-16T20:52:14.123Z sideways"} {
+		if _, ok := parse(line); ok {
+			t.Errorf("parse(%q) succeeded", line)
+		}
+	}
+}
+
+func TestJournal(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, 10, 16, 20, 0, 0, 0, time.UTC)
+
+	// Every record takes 28 bytes, so the journal is rotated before every fourth one.
+	journal := NewJournal(t.TempDir(), "main", 80)
+
+	var appended []Record
+
+	for i := range 7 {
+		record := Record{At: start.Add(time.Duration(i) * time.Minute), Kind: KindUp}
+		if err := journal.Append(record); err != nil {
+			t.Fatal(err)
+		}
+
+		appended = append(appended, record)
+	}
+
+	// A line cut short by a power loss is skipped.
+	file, err := os.OpenFile(journal.Path(), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := file.WriteString("2026-10-16T20:07"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := journal.Records()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest records were dropped by the second rotation, the backup and the journal are read in order.
+	if want := appended[3:]; !reflect.DeepEqual(records, want) {
+		t.Fatalf("Records() = %v, want %v", records, want)
+	}
+}
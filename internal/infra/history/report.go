@@ -0,0 +1,159 @@
+package history
+
+import "time"
+
+// Outage is an interval in which the agent was running but not connected to the server.
+type Outage struct {
+	// Start is the time the agent lost the server or started without it, the start of the window if it was earlier.
+	Start time.Time
+	// End is the time the agent connected again or stopped, the end of the window if it is ongoing.
+	End time.Time
+	// Code is the gRPC code of the first error of the outage, empty if there was none.
+	Code string
+	// Ongoing is set if the outage had not ended at the end of the window.
+	Ongoing bool
+}
+
+// Duration returns the duration of the outage.
+func (o Outage) Duration() time.Duration {
+	return o.End.Sub(o.Start)
+}
+
+// Report is the connectivity of the agent within a window.
+type Report struct {
+	// From is the start of the window.
+	From time.Time
+	// To is the end of the window.
+	To time.Time
+	// Outages are the outages that overlap the window, the oldest first.
+	// They are cut to the window, so that their durations add up to the downtime.
+	Outages []Outage
+	// Uptime is the time within the window the agent was connected.
+	Uptime time.Duration
+	// Downtime is the time within the window the agent was running but not connected.
+	Downtime time.Duration
+	// Unknown is the time within the window the agent was not running, starting up or not recorded yet.
+	Unknown time.Duration
+}
+
+// Availability returns the share of the time the agent was connected while it was running, in percent.
+//
+// Returns:
+// - float64: The availability in percent.
+// - bool: Whether the agent was running within the window at all.
+func (r Report) Availability() (float64, bool) {
+	monitored := r.Uptime + r.Downtime
+	if monitored <= 0 {
+		return 0, false
+	}
+
+	return float64(r.Uptime) / float64(monitored) * 100, true //nolint:mnd
+}
+
+// Summarize computes the connectivity of the agent within the window from the records of the journal.
+//
+// Every record sets the state of the agent until the next one: connected after an up record,
+// disconnected after a start or a down record and unknown after a stop record or before the first one.
+// The start up of an agent that connected without a failure is unknown as well, it is not an outage.
+// The state of the last record lasts until the end of the window, so the state of an agent
+// that crashed is extended until it is started again.
+//
+// Parameters:
+// - records: The records of the journal, the oldest first.
+// - from: The start of the window.
+// - to: The end of the window.
+//
+// Returns:
+// - Report: The connectivity within the window.
+func Summarize(records []Record, from, to time.Time) Report {
+	report := Report{From: from, To: to}
+
+	var outage *Outage
+
+	// The time before the first record is not recorded.
+	if len(records) == 0 {
+		report.Unknown = to.Sub(from)
+
+		return report
+	}
+
+	report.Unknown += overlap(from, to, from, records[0].At)
+
+	for i, record := range records {
+		end := to
+		if i+1 < len(records) {
+			end = records[i+1].At
+		}
+
+		spent := overlap(from, to, record.At, end)
+
+		kind := record.Kind
+
+		// An agent that connected right after it started had no outage, it was starting up.
+		if kind == KindStart && i+1 < len(records) && records[i+1].Kind == KindUp {
+			kind = KindStop
+		}
+
+		switch kind {
+		case KindUp:
+			report.Uptime += spent
+		case KindStart, KindDown:
+			report.Downtime += spent
+
+			if outage == nil {
+				outage = &Outage{Start: record.At}
+			}
+
+			if outage.Code == "" {
+				outage.Code = record.Code
+			}
+
+			outage.End = end
+		case KindStop:
+			report.Unknown += spent
+		}
+
+		// The outage ends with the next record that is not a disconnected one, or with the window.
+		if outage != nil && (i+1 == len(records) || records[i+1].Kind == KindUp || records[i+1].Kind == KindStop) {
+			outage.Ongoing = i+1 == len(records) || outage.End.After(to)
+
+			if outage.End.After(from) && outage.Start.Before(to) {
+				report.Outages = append(report.Outages, clamp(*outage, from, to))
+			}
+
+			outage = nil
+		}
+	}
+
+	return report
+}
+
+// clamp cuts the outage to the window [from, to).
+func clamp(outage Outage, from, to time.Time) Outage {
+	if outage.Start.Before(from) {
+		outage.Start = from
+	}
+
+	if outage.End.After(to) {
+		outage.End = to
+	}
+
+	return outage
+}
+
+// overlap returns the time the interval [start, end) overlaps the window [from, to).
+func overlap(from, to, start, end time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+
+	if end.After(to) {
+		end = to
+	}
+
+	if !end.After(start) {
+		return 0
+	}
+
+	return end.Sub(start)
+}
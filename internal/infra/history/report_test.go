@@ -0,0 +1,97 @@
+package history
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+
+	from := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+
+	// at returns the time the given number of minutes after the start of the window.
+	at := func(minutes int) time.Time {
+		return from.Add(time.Duration(minutes) * time.Minute)
+	}
+
+	tests := []struct {
+		name    string
+		records []Record
+		want    Report
+	}{
+		{
+			name: "no records",
+			want: Report{Unknown: 10 * time.Hour},
+		},
+		{
+			name: "outage within the window",
+			records: []Record{
+				{At: at(-120), Kind: KindStart},
+				{At: at(-119), Kind: KindUp},
+				{At: at(120), Kind: KindDown, Code: "Unavailable"},
+				{At: at(180), Kind: KindDown, Code: "DeadlineExceeded"},
+				{At: at(240), Kind: KindUp},
+				{At: at(480), Kind: KindStop},
+			},
+			want: Report{
+				Outages:  []Outage{{Start: at(120), End: at(240), Code: "Unavailable"}},
+				Uptime:   6 * time.Hour,
+				Downtime: 2 * time.Hour,
+				Unknown:  2 * time.Hour,
+			},
+		},
+		{
+			name: "outage that started before the window",
+			records: []Record{
+				{At: at(-180), Kind: KindUp},
+				{At: at(-60), Kind: KindDown, Code: "DeadlineExceeded"},
+				{At: at(60), Kind: KindUp},
+			},
+			want: Report{
+				Outages:  []Outage{{Start: at(0), End: at(60), Code: "DeadlineExceeded"}},
+				Uptime:   9 * time.Hour,
+				Downtime: time.Hour,
+			},
+		},
+		{
+			name: "ongoing outage",
+			records: []Record{
+				{At: at(0), Kind: KindUp},
+				{At: at(540), Kind: KindDown, Code: "Unavailable"},
+			},
+			want: Report{
+				Outages:  []Outage{{Start: at(540), End: at(600), Code: "Unavailable", Ongoing: true}},
+				Uptime:   9 * time.Hour,
+				Downtime: time.Hour,
+			},
+		},
+		{
+			name: "outage that ended after the window",
+			records: []Record{
+				{At: at(0), Kind: KindUp},
+				{At: at(540), Kind: KindDown, Code: "Unavailable"},
+				{At: at(660), Kind: KindUp},
+			},
+			want: Report{
+				Outages:  []Outage{{Start: at(540), End: at(600), Code: "Unavailable", Ongoing: true}},
+				Uptime:   9 * time.Hour,
+				Downtime: time.Hour,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tt.want.From, tt.want.To = from, to
+
+			if got := Summarize(tt.records, from, to); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Summarize() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
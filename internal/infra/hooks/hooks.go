@@ -0,0 +1,270 @@
+// Package hooks runs the local commands configured for the changes of the connection of the agent,
+// e.g. to restart a modem or to switch to a failover route when the server is unreachable.
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Event is the change of the connection a hook is run for.
+type Event string
+
+const (
+	// EventConnect is the event of a stream to the server that has been established.
+	EventConnect Event = "connect"
+	// EventDisconnect is the event of a stream to the server that failed.
+	EventDisconnect Event = "disconnect"
+	// EventOutage is the event of a server that has been unreachable for longer than the outage threshold.
+	EventOutage Event = "outage"
+)
+
+// maxOutput is the maximum number of bytes of the output of a hook that is logged.
+const maxOutput = 4096
+
+// Options holds the settings of the hooks.
+type Options struct {
+	// Commands are the shell commands run for the events, the events without a command are ignored.
+	Commands map[Event]string
+	// OutageAfter is the time the server must be unreachable before the outage hook is run.
+	OutageAfter time.Duration
+	// Timeout is the maximum run time of a hook, the hook is killed once it expires.
+	Timeout time.Duration
+	// MinInterval is the minimum time between two runs of the hook of the same event.
+	// The events that occur meanwhile are skipped.
+	MinInterval time.Duration
+	// Env are the environment variables passed to every hook in addition to the event ones,
+	// in the "KEY=value" format. They are replaced by SetEnv.
+	Env []string
+}
+
+// Hooks runs the hooks of the connection events.
+//
+// The agent is considered disconnected from the start until the first stream is established,
+// so that the outage hook is also run if the server is unreachable when the agent starts.
+type Hooks struct {
+	// ctx provides the logger and stops the outage timer.
+	ctx context.Context
+	// opts are the settings of the hooks.
+	opts Options
+	// running tracks the hooks that are running.
+	running sync.WaitGroup
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// down is the start of the current outage, zero while connected.
+	down time.Time
+	// outage runs the outage hook once the outage lasts longer than the threshold.
+	outage *time.Timer
+	// lastRun is the time of the last run of the hook of every event.
+	lastRun map[Event]time.Time
+}
+
+// New creates the hooks of an agent that is not connected yet.
+//
+// Parameters:
+// - ctx: The context that stops the outage timer, it provides the logger.
+// - opts: The settings of the hooks.
+//
+// Returns:
+// - *Hooks: A pointer to the Hooks instance.
+func New(ctx context.Context, opts Options) *Hooks {
+	h := &Hooks{ctx: ctx, opts: opts, lastRun: make(map[Event]time.Time)}
+
+	h.mu.Lock()
+	h.disconnect(time.Now())
+	h.mu.Unlock()
+
+	// No outage is reported once the agent stops.
+	context.AfterFunc(ctx, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.stopOutage()
+	})
+
+	return h
+}
+
+// Connected runs the connect hook once a stream to the server has been established.
+//
+// Parameters:
+// - at: The time the stream was established.
+func (h *Hooks) Connected(at time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	downtime := at.Sub(h.down)
+
+	h.down = time.Time{}
+	h.stopOutage()
+
+	h.run(EventConnect, at, "VAKEEL_DOWNTIME="+seconds(downtime))
+}
+
+// Disconnected runs the disconnect hook once the stream to the server failed.
+//
+// Parameters:
+// - at: The time the stream failed.
+// - err: The error of the stream, nil if there was none.
+func (h *Hooks) Disconnected(at time.Time, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.disconnect(at)
+
+	message := ""
+	if err != nil {
+		message = err.Error()
+	}
+
+	h.run(EventDisconnect, at, "VAKEEL_ERROR="+message)
+}
+
+// SetEnv replaces the environment variables passed to the hooks started from now on,
+// e.g. once the agent IDs are reloaded.
+//
+// Parameters:
+// - env: The environment variables in the "KEY=value" format.
+func (h *Hooks) SetEnv(env []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.opts.Env = env
+}
+
+// Wait waits for the running hooks to finish, they are killed once their timeout expires.
+func (h *Hooks) Wait() {
+	h.running.Wait()
+}
+
+// disconnect records the start of an outage and starts the outage timer.
+// The caller must hold the mutex.
+func (h *Hooks) disconnect(at time.Time) {
+	h.down = at
+	h.stopOutage()
+
+	if h.opts.Commands[EventOutage] == "" || h.ctx.Err() != nil {
+		return
+	}
+
+	h.outage = time.AfterFunc(h.opts.OutageAfter, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		// The agent reconnected or stopped meanwhile.
+		if h.down != at || h.ctx.Err() != nil {
+			return
+		}
+
+		now := time.Now()
+		h.run(EventOutage, now, "VAKEEL_DOWNTIME="+seconds(now.Sub(at)))
+	})
+}
+
+// stopOutage stops the outage timer. The caller must hold the mutex.
+func (h *Hooks) stopOutage() {
+	if h.outage != nil {
+		h.outage.Stop()
+		h.outage = nil
+	}
+}
+
+// run starts the hook of the event in the background unless it ran less than the minimum interval ago.
+// The caller must hold the mutex.
+//
+// Parameters:
+// - event: The event of the hook.
+// - at: The time of the event.
+// - env: The environment variables describing the event.
+func (h *Hooks) run(event Event, at time.Time, env ...string) {
+	command := h.opts.Commands[event]
+	if command == "" {
+		return
+	}
+
+	logger := zerolog.Ctx(h.ctx).With().Str("hook", string(event)).Logger()
+
+	if last, ok := h.lastRun[event]; ok && at.Sub(last) < h.opts.MinInterval {
+		logger.Warn().Dur("min_interval", h.opts.MinInterval).Msg("hook skipped, it ran recently")
+
+		return
+	}
+
+	h.lastRun[event] = at
+
+	env = append(slices.Clone(h.opts.Env), append([]string{
+		"VAKEEL_EVENT=" + string(event),
+		"VAKEEL_TIME=" + at.Format(time.RFC3339),
+	}, env...)...)
+
+	h.running.Add(1)
+
+	go func() {
+		defer h.running.Done()
+
+		// The hook is not killed when the agent stops, only when its timeout expires.
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(h.ctx), h.opts.Timeout)
+		defer cancel()
+
+		execute(ctx, logger, command, env)
+	}()
+}
+
+// execute runs the shell command and logs its result.
+//
+// Parameters:
+// - ctx: The context that kills the command once it is done.
+// - logger: The logger of the hook.
+// - command: The shell command.
+// - env: The environment variables passed in addition to the ones of the agent.
+func execute(ctx context.Context, logger zerolog.Logger, command string, env []string) {
+	var output bytes.Buffer
+
+	cmd := exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	killGroup(cmd)
+
+	started := time.Now()
+	err := cmd.Run()
+
+	event := logger.Info()
+	if err != nil {
+		event = logger.Error().Err(err)
+
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			event = event.Bool("timeout", true)
+		}
+	}
+
+	event.
+		Dur("duration", time.Since(started)).
+		Str("output", truncate(strings.TrimSpace(output.String()))).
+		Msg("hook finished")
+}
+
+// truncate returns at most maxOutput bytes of the output.
+func truncate(output string) string {
+	if len(output) > maxOutput {
+		return output[:maxOutput] + "..."
+	}
+
+	return output
+}
+
+// seconds formats the duration as whole seconds.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
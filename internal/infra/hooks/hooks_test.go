@@ -0,0 +1,152 @@
+//go:build unix
+
+package hooks
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestScript writes a hook script that appends its event and the given variable to a file,
+// and returns the command that runs it and the path to the file.
+func newTestScript(t *testing.T, body string) (string, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	output := filepath.Join(dir, "events")
+	script := filepath.Join(dir, "hook.sh")
+
+	content := "#!/bin/sh\n" + body + "\necho \"$VAKEEL_EVENT $VAKEEL_IDS\" >> " + output + "\n"
+	if err := os.WriteFile(script, []byte(content), 0o700); err != nil { //nolint:gosec
+		t.Fatal(err)
+	}
+
+	return script, output
+}
+
+// readEvents returns the lines the hook script appended.
+func readEvents(t *testing.T, path string) []string {
+	t.Helper()
+
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.Split(strings.TrimSpace(string(content)), "\n")
+}
+
+func TestHooksMinInterval(t *testing.T) {
+	t.Parallel()
+
+	script, output := newTestScript(t, "")
+	at := time.Now()
+
+	hooks := New(context.Background(), Options{
+		Commands:    map[Event]string{EventConnect: script, EventDisconnect: script},
+		Timeout:     5 * time.Second,
+		MinInterval: time.Minute,
+		Env:         []string{"VAKEEL_IDS=a"},
+	})
+
+	// The second connect within the minimum interval is skipped, the other events are not.
+	hooks.Connected(at)
+	hooks.Disconnected(at.Add(time.Second), nil)
+	hooks.SetEnv([]string{"VAKEEL_IDS=b"})
+	hooks.Connected(at.Add(2 * time.Second))
+	hooks.Connected(at.Add(time.Minute))
+	hooks.Wait()
+
+	got := readEvents(t, output)
+	if len(got) != 3 || !strings.Contains(strings.Join(got, ","), "connect b") {
+		t.Fatalf("hooks run = %q, want 3 runs and the reloaded IDs", got)
+	}
+}
+
+func TestHooksTimeout(t *testing.T) {
+	t.Parallel()
+
+	script, output := newTestScript(t, "sleep 30")
+
+	hooks := New(context.Background(), Options{
+		Commands: map[Event]string{EventConnect: script},
+		Timeout:  100 * time.Millisecond,
+	})
+
+	started := time.Now()
+
+	hooks.Connected(started)
+	hooks.Wait()
+
+	// The hook and the sleep it started are killed together.
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("the hook ran for %v, want it killed after its timeout", elapsed)
+	}
+
+	if got := readEvents(t, output); got != nil {
+		t.Fatalf("the hook finished: %q", got)
+	}
+}
+
+func TestHooksOutage(t *testing.T) {
+	t.Parallel()
+
+	script, output := newTestScript(t, "")
+
+	// The agent is unreachable from its start, so the outage hook runs without a disconnect.
+	hooks := New(context.Background(), Options{
+		Commands:    map[Event]string{EventOutage: script},
+		OutageAfter: 50 * time.Millisecond,
+		Timeout:     5 * time.Second,
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for readEvents(t, output) == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("the outage hook did not run")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The agent connects once the outage hook ran, which waits for the outage timer to finish.
+	hooks.Connected(time.Now())
+	hooks.Wait()
+
+	if got := readEvents(t, output); len(got) != 1 || got[0] != "outage" {
+		t.Fatalf("hooks run = %q, want the outage hook once", got)
+	}
+}
+
+func TestHooksOutageCancelled(t *testing.T) {
+	t.Parallel()
+
+	script, output := newTestScript(t, "")
+
+	hooks := New(context.Background(), Options{
+		Commands:    map[Event]string{EventOutage: script},
+		OutageAfter: 200 * time.Millisecond,
+		Timeout:     5 * time.Second,
+	})
+
+	// Reconnecting within the threshold cancels the outage timer, the next disconnect starts it over.
+	hooks.Connected(time.Now())
+	hooks.Disconnected(time.Now(), nil)
+	time.Sleep(100 * time.Millisecond)
+	hooks.Connected(time.Now())
+
+	time.Sleep(300 * time.Millisecond)
+	hooks.Wait()
+
+	if got := readEvents(t, output); got != nil {
+		t.Fatalf("hooks run = %q, want no outage hook", got)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package hooks
+
+import (
+	"os/exec"
+	"time"
+)
+
+// waitDelay is the time to wait for the output of the killed processes of a hook.
+const waitDelay = time.Second
+
+// killGroup stops waiting for the processes started by the shell once the command is killed,
+// process groups are not supported on this platform.
+func killGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = waitDelay
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package hooks
+
+import (
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// waitDelay is the time to wait for the output of the killed processes of a hook.
+const waitDelay = time.Second
+
+// killGroup runs the command in its own process group, so that the processes started
+// by the shell are killed together with it once the timeout expires.
+func killGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = waitDelay
+}
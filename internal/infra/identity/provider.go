@@ -0,0 +1,228 @@
+package identity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+
+	"github.com/bavix/vakeel/pkg/atomicfile"
+	"github.com/bavix/vakeel/pkg/ctxid"
+)
+
+// Source is the source the agent ID is derived from when it is not configured explicitly.
+type Source string
+
+const (
+	// SourceAuto keeps the agent ID of the state file once there is one, derives it from the machine ID
+	// otherwise and falls back to generating it in the state file.
+	SourceAuto Source = "auto"
+	// SourceMachineID derives the agent ID from the machine ID.
+	SourceMachineID Source = "machine-id"
+	// SourceState generates the agent ID once and persists it in the state directory.
+	SourceState Source = "state"
+)
+
+// DefaultNamespace is the default namespace of the agent IDs derived from the machine ID.
+//
+// It is the UUIDv5 of "https://github.com/bavix/vakeel" in the URL namespace.
+const DefaultNamespace = "57ceae96-cb22-5768-a2d3-0b7ee40e69b8"
+
+// stateFileName is the name of the file in the state directory that holds the generated agent ID.
+const stateFileName = "id"
+
+// ErrNoState is the error returned by Lookup when the agent ID has not been generated in the state directory yet.
+var ErrNoState = errors.New("agent ID not generated yet")
+
+// errUnknownSource is the error returned when the identity source is not supported.
+var errUnknownSource = errors.New("unknown identity source")
+
+// errNoMachineID is the error returned when none of the machine ID files can be read.
+var errNoMachineID = errors.New("machine ID not found")
+
+// machineIDPattern matches a valid machine ID, see machine-id(5).
+var machineIDPattern = regexp.MustCompile(`^[0-9a-fA-F]{32}$`)
+
+// machineIDPaths are the files that contain the machine ID, in order of preference.
+//
+// /etc/machine-id is maintained by systemd, /var/lib/dbus/machine-id by D-Bus
+// on systems without systemd.
+//
+//nolint:gochecknoglobals
+var machineIDPaths = []string{
+	"/etc/machine-id",
+	"/var/lib/dbus/machine-id",
+}
+
+// Provider provides a stable agent ID for the current machine.
+type Provider struct {
+	// namespace is the namespace of the agent IDs derived from the machine ID.
+	namespace uuid.UUID
+	// stateDir is the directory where the generated agent ID is persisted.
+	stateDir string
+	// machineIDPaths are the files that contain the machine ID, in order of preference.
+	machineIDPaths []string
+}
+
+// New creates a new Provider instance.
+//
+// Parameters:
+// - namespace: The namespace of the agent IDs derived from the machine ID.
+// - stateDir: The directory where the generated agent ID is persisted.
+//
+// Returns:
+// - *Provider: A pointer to the Provider instance.
+func New(namespace uuid.UUID, stateDir string) *Provider {
+	return &Provider{
+		namespace:      namespace,
+		stateDir:       stateDir,
+		machineIDPaths: machineIDPaths,
+	}
+}
+
+// ID returns the agent ID derived from the given source.
+//
+// Parameters:
+// - source: The source the agent ID is derived from.
+//
+// Returns:
+// - uuid.UUID: The agent ID.
+// - error: An error if the agent ID cannot be derived from the source.
+func (p *Provider) ID(source Source) (uuid.UUID, error) {
+	switch source {
+	case SourceMachineID:
+		return p.FromMachineID()
+	case SourceState:
+		return p.FromState()
+	case SourceAuto:
+		// Keep the agent ID of the state file, so that the ID does not change once a machine ID shows up.
+		if id, err := p.readState(); !errors.Is(err, ErrNoState) {
+			return id, err
+		}
+
+		// Prefer the machine ID, it survives a wiped state directory.
+		if id, err := p.FromMachineID(); err == nil {
+			return id, nil
+		}
+
+		return p.FromState()
+	default:
+		return uuid.Nil, fmt.Errorf("%w: %q", errUnknownSource, source)
+	}
+}
+
+// Lookup returns the agent ID derived from the given source without generating it.
+//
+// Unlike ID, it never writes the state file, so it can be used by diagnostics.
+//
+// Parameters:
+// - source: The source the agent ID is derived from.
+//
+// Returns:
+// - uuid.UUID: The agent ID.
+// - error: ErrNoState if the agent ID would be generated, or an error if it cannot be derived from the source.
+func (p *Provider) Lookup(source Source) (uuid.UUID, error) {
+	switch source {
+	case SourceMachineID:
+		return p.FromMachineID()
+	case SourceState:
+		return p.readState()
+	case SourceAuto:
+		if id, err := p.readState(); !errors.Is(err, ErrNoState) {
+			return id, err
+		}
+
+		if id, err := p.FromMachineID(); err == nil {
+			return id, nil
+		}
+
+		return p.readState()
+	default:
+		return uuid.Nil, fmt.Errorf("%w: %q", errUnknownSource, source)
+	}
+}
+
+// FromMachineID derives the agent ID from the machine ID.
+//
+// The machine ID is hashed into a UUIDv5 under the namespace of the provider,
+// so the machine ID itself is never sent to the server.
+//
+// Files that do not hold a valid machine ID are skipped, e.g. an empty file in an image or
+// the "uninitialized" machine ID of systemd on the first boot, which every such device shares.
+//
+// Returns:
+// - uuid.UUID: The agent ID.
+// - error: An error if none of the machine ID files holds a valid machine ID.
+func (p *Provider) FromMachineID() (uuid.UUID, error) {
+	for _, path := range p.machineIDPaths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		machineID := strings.TrimSpace(string(content))
+		if !machineIDPattern.MatchString(machineID) {
+			continue
+		}
+
+		return uuid.NewSHA1(p.namespace, []byte(machineID)), nil
+	}
+
+	return uuid.Nil, errNoMachineID
+}
+
+// FromState reads the agent ID from the state directory.
+//
+// If the state file does not exist yet, a new random agent ID is generated and persisted.
+// A state file that does not hold a valid agent ID is an error, it is not replaced, so that
+// the agent does not silently report a different ID.
+//
+// Returns:
+// - uuid.UUID: The agent ID.
+// - error: An error if the state file cannot be read, parsed or written, or holds the nil UUID.
+func (p *Provider) FromState() (uuid.UUID, error) {
+	// Read the persisted agent ID if there is one.
+	id, err := p.readState()
+	if !errors.Is(err, ErrNoState) {
+		return id, err
+	}
+
+	// Generate a new agent ID and persist it.
+	id = uuid.New()
+
+	if err := atomicfile.WriteFile(p.statePath(), []byte(id.String()+"\n"), 0o644); err != nil { //nolint:mnd
+		return uuid.Nil, err
+	}
+
+	return id, nil
+}
+
+// readState reads the agent ID from the state file, ErrNoState is returned if the file does not exist.
+func (p *Provider) readState() (uuid.UUID, error) {
+	path := p.statePath()
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return uuid.Nil, fmt.Errorf("%w in %s", ErrNoState, path)
+	}
+
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	id, err := ctxid.Parse(strings.TrimSpace(string(content)))
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid agent ID in %s: %w", path, err)
+	}
+
+	return id, nil
+}
+
+// statePath returns the path to the state file that holds the generated agent ID.
+func (p *Provider) statePath() string {
+	return filepath.Join(p.stateDir, stateFileName)
+}
@@ -0,0 +1,136 @@
+package identity
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// newTestProvider creates a provider with a state directory and a machine ID file with the given content.
+// The machine ID file is missing if the content is empty.
+func newTestProvider(t *testing.T, machineID string) *Provider {
+	t.Helper()
+
+	dir := t.TempDir()
+	provider := New(uuid.MustParse(DefaultNamespace), dir)
+	provider.machineIDPaths = []string{filepath.Join(dir, "machine-id")}
+
+	if machineID != "" {
+		writeTestFile(t, provider.machineIDPaths[0], machineID)
+	}
+
+	return provider
+}
+
+// writeTestFile writes the content to the file.
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFromMachineID(t *testing.T) {
+	t.Parallel()
+
+	provider := newTestProvider(t, "0123456789abcdef0123456789abcdef\n")
+
+	id, err := provider.FromMachineID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := uuid.NewSHA1(provider.namespace, []byte("0123456789abcdef0123456789abcdef")); id != want {
+		t.Fatalf("FromMachineID() = %s, want %s", id, want)
+	}
+
+	for _, content := range []string{"", "\n", "uninitialized\n", "0123456789abcdef", "router-0123456789abcdef0123456789"} {
+		provider := newTestProvider(t, content)
+
+		if id, err := provider.FromMachineID(); !errors.Is(err, errNoMachineID) {
+			t.Errorf("FromMachineID() with %q = %s, %v, want %v", content, id, err, errNoMachineID)
+		}
+	}
+}
+
+func TestFromState(t *testing.T) {
+	t.Parallel()
+
+	provider := newTestProvider(t, "")
+
+	if _, err := provider.Lookup(SourceState); !errors.Is(err, ErrNoState) {
+		t.Fatalf("Lookup() before the first run error = %v, want %v", err, ErrNoState)
+	}
+
+	id, err := provider.FromState()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The generated agent ID is persisted and read back on the next run.
+	for _, source := range []Source{SourceState, SourceAuto} {
+		again, err := provider.ID(source)
+		if err != nil || again != id {
+			t.Fatalf("ID(%s) = %s, %v, want %s", source, again, err, id)
+		}
+	}
+}
+
+func TestFromStateInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, content := range []string{"router\n", uuid.Nil.String() + "\n"} {
+		provider := newTestProvider(t, "")
+		writeTestFile(t, provider.statePath(), content)
+
+		// The state file is not replaced, the agent would report a different ID otherwise.
+		if id, err := provider.FromState(); err == nil {
+			t.Errorf("FromState() with %q = %s, want an error", content, id)
+		}
+
+		if got, err := os.ReadFile(provider.statePath()); err != nil || string(got) != content {
+			t.Errorf("the state file = %q, %v, want %q", got, err, content)
+		}
+	}
+}
+
+func TestAutoKeepsState(t *testing.T) {
+	t.Parallel()
+
+	// Without a machine ID the agent ID is generated in the state file.
+	provider := newTestProvider(t, "")
+
+	id, err := provider.ID(SourceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A machine ID that shows up later does not change the agent ID.
+	writeTestFile(t, provider.machineIDPaths[0], "0123456789abcdef0123456789abcdef\n")
+
+	for _, derive := range []func(Source) (uuid.UUID, error){provider.ID, provider.Lookup} {
+		if again, err := derive(SourceAuto); err != nil || again != id {
+			t.Fatalf("agent ID = %s, %v, want %s", again, err, id)
+		}
+	}
+
+	// The machine ID is used as long as there is no state file.
+	fresh := newTestProvider(t, "0123456789abcdef0123456789abcdef\n")
+
+	derived, err := fresh.ID(SourceAuto)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := uuid.NewSHA1(fresh.namespace, []byte("0123456789abcdef0123456789abcdef")); derived != want {
+		t.Fatalf("ID() = %s, want %s", derived, want)
+	}
+
+	if _, err := os.Stat(fresh.statePath()); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the state file was written: %v", err)
+	}
+}
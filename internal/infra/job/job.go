@@ -0,0 +1,119 @@
+// Package job runs a command on behalf of the agent, e.g. a backup, and reports how it ended.
+package job
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"time"
+)
+
+// Exit codes of the jobs that did not exit on their own, as used by the shells and timeout(1).
+const (
+	// ExitTimeout is the exit code of a job that was stopped once its timeout expired.
+	ExitTimeout = 124
+	// ExitCannotRun is the exit code of a job whose command cannot be run.
+	ExitCannotRun = 126
+	// ExitNotFound is the exit code of a job whose command is not found.
+	ExitNotFound = 127
+	// exitSignal is added to the number of the signal that killed a job.
+	exitSignal = 128
+)
+
+// stopDelay is the time a job is given to exit once it has been asked to stop, it is killed afterwards.
+const stopDelay = 10 * time.Second
+
+// errEmptyCommand is the error returned when the job has no command.
+var errEmptyCommand = errors.New("no command to run")
+
+// Options holds the command of a job and where its input and output go.
+type Options struct {
+	// Args are the command and its arguments.
+	Args []string
+	// Stdin is the standard input of the command.
+	Stdin io.Reader
+	// Stdout is the standard output of the command.
+	Stdout io.Writer
+	// Stderr is the standard error of the command.
+	Stderr io.Writer
+	// Timeout is the maximum run time of the command, zero for no limit.
+	Timeout time.Duration
+}
+
+// Result is how a job ended.
+type Result struct {
+	// ExitCode is the exit code of the command, ExitTimeout if it timed out and 128 plus the signal
+	// if it was killed by a signal.
+	ExitCode int
+	// TimedOut is set if the command was stopped once its timeout expired.
+	TimedOut bool
+	// Duration is the run time of the command.
+	Duration time.Duration
+}
+
+// Succeeded reports whether the command exited with zero within its timeout.
+func (r Result) Succeeded() bool {
+	return r.ExitCode == 0 && !r.TimedOut
+}
+
+// Run runs the command and waits for it to exit.
+//
+// The command runs in its own process group, which is asked to stop with SIGTERM once the
+// timeout expires or the context is cancelled, and is killed if it does not exit in time.
+// A command whose standard input is a terminal stays in the process group of the caller.
+//
+// Parameters:
+// - ctx: The context that stops the command.
+// - opts: The command and its input and output.
+//
+// Returns:
+// - Result: How the command ended.
+// - error: An error if the command cannot be started, the result then has the exit code of the shells.
+func Run(ctx context.Context, opts Options) (Result, error) {
+	if len(opts.Args) == 0 {
+		return Result{ExitCode: ExitCannotRun}, errEmptyCommand
+	}
+
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, opts.Args[0], opts.Args[1:]...) //nolint:gosec
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	stopGroup(cmd)
+
+	started := time.Now()
+
+	if err := cmd.Start(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return Result{ExitCode: ExitNotFound}, err
+		}
+
+		return Result{ExitCode: ExitCannotRun}, err
+	}
+
+	err := cmd.Wait()
+	result := Result{ExitCode: cmd.ProcessState.ExitCode(), Duration: time.Since(started)}
+
+	if signal, ok := signaled(cmd.ProcessState); ok {
+		result.ExitCode = exitSignal + signal
+	}
+
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) && opts.Timeout > 0 {
+		result.ExitCode, result.TimedOut = ExitTimeout, true
+	}
+
+	// The exit code of the command is the result, the errors of copying its output are not.
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay) {
+		return result, err
+	}
+
+	return result, nil
+}
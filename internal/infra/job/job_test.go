@@ -0,0 +1,67 @@
+//go:build unix
+
+package job
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestRunExitCode(t *testing.T) {
+	t.Parallel()
+
+	var stdout bytes.Buffer
+
+	result, err := Run(context.Background(), Options{
+		Args:   []string{"sh", "-c", "echo out; exit 3"},
+		Stdout: &stdout,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.ExitCode != 3 || result.TimedOut || result.Succeeded() {
+		t.Fatalf("Run() = %+v, want the exit code 3", result)
+	}
+
+	if stdout.String() != "out\n" {
+		t.Fatalf("stdout = %q, want %q", stdout.String(), "out\n")
+	}
+}
+
+func TestRunNotFound(t *testing.T) {
+	t.Parallel()
+
+	result, err := Run(context.Background(), Options{Args: []string{"vakeel-missing-command"}})
+	if !errors.Is(err, exec.ErrNotFound) || result.ExitCode != ExitNotFound {
+		t.Fatalf("Run() = %+v, %v, want the exit code %d", result, err, ExitNotFound)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	t.Parallel()
+
+	// The child of the shell is stopped together with it, otherwise Wait would block on its output.
+	var stdout bytes.Buffer
+
+	result, err := Run(context.Background(), Options{
+		Args:    []string{"sh", "-c", "sleep 30 & wait"},
+		Stdout:  &stdout,
+		Timeout: 100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result.ExitCode != ExitTimeout || !result.TimedOut || result.Succeeded() {
+		t.Fatalf("Run() = %+v, want a timeout", result)
+	}
+
+	if result.Duration >= stopDelay {
+		t.Fatalf("Run() took %v, the job was not stopped by SIGTERM", result.Duration)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build !unix
+
+package job
+
+import (
+	"os"
+	"os/exec"
+)
+
+// stopGroup kills the command once it is asked to stop, process groups are not supported on this platform.
+func stopGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = stopDelay
+}
+
+// signaled reports no signal, the processes are not killed by signals on this platform.
+func signaled(*os.ProcessState) (int, bool) {
+	return 0, false
+}
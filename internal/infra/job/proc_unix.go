@@ -0,0 +1,44 @@
+//go:build unix
+
+package job
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+
+	"github.com/mattn/go-isatty"
+)
+
+// stopGroup runs the command in its own process group, so that the processes it started are
+// asked to stop together with it. The command is killed if it does not exit within the stop delay.
+//
+// A command that reads from a terminal stays in the foreground process group of vakeel, it would be
+// stopped by SIGTTIN otherwise. Only the command itself is asked to stop then, the terminal sends
+// the interrupts typed by the user to all the processes of the group anyway.
+func stopGroup(cmd *exec.Cmd) {
+	if file, ok := cmd.Stdin.(*os.File); ok && isatty.IsTerminal(file.Fd()) {
+		cmd.Cancel = func() error {
+			return cmd.Process.Signal(syscall.SIGTERM)
+		}
+		cmd.WaitDelay = stopDelay
+
+		return
+	}
+
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+	}
+	cmd.WaitDelay = stopDelay
+}
+
+// signaled returns the number of the signal that killed the process.
+func signaled(state *os.ProcessState) (int, bool) {
+	status, ok := state.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return 0, false
+	}
+
+	return int(status.Signal()), true
+}
@@ -0,0 +1,103 @@
+// Package led drives a LED of the Linux LED class, e.g. the status LED on the front panel of a router.
+package led
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultRoot is the sysfs directory of the LED class devices.
+const DefaultRoot = "/sys/class/leds"
+
+// Triggers of the LED class, see the kernel documentation of the LED class.
+const (
+	// triggerNone keeps the LED at the brightness that is written.
+	triggerNone = "none"
+	// triggerTimer blinks the LED with the delays that are written.
+	triggerTimer = "timer"
+)
+
+// fileMode is the mode of the attribute files, which only matters when they do not exist, e.g. in tests.
+const fileMode = 0o644
+
+// errNotFound is the error returned when the LED does not exist.
+var errNotFound = errors.New("LED not found")
+
+// LED is a LED under the sysfs root, i.e. the directory <root>/<name> with its attribute files.
+type LED struct {
+	// dir is the directory of the LED.
+	dir string
+	// maxBrightness is the brightness of the LED when it is on.
+	maxBrightness string
+}
+
+// Open opens the LED with the given name under the sysfs root.
+//
+// Parameters:
+// - root: The sysfs directory of the LED class devices, usually DefaultRoot.
+// - name: The name of the LED, e.g. "green:status".
+//
+// Returns:
+// - *LED: A pointer to the LED instance.
+// - error: An error if the LED does not exist or its maximum brightness cannot be read.
+func Open(root, name string) (*LED, error) {
+	dir := filepath.Join(root, name)
+
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%w: %s", errNotFound, dir)
+	}
+
+	maxBrightness, err := os.ReadFile(filepath.Join(dir, "max_brightness"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the maximum brightness of the LED: %w", err)
+	}
+
+	return &LED{dir: dir, maxBrightness: strings.TrimSpace(string(maxBrightness))}, nil
+}
+
+// On turns the LED on.
+func (l *LED) On() error {
+	return l.set(triggerNone, l.maxBrightness)
+}
+
+// Off turns the LED off.
+func (l *LED) Off() error {
+	return l.set(triggerNone, "0")
+}
+
+// Blink blinks the LED with the given on and off times.
+func (l *LED) Blink(on, off time.Duration) error {
+	// The delay files are created by the kernel once the timer trigger is set.
+	if err := l.write("trigger", triggerTimer); err != nil {
+		return err
+	}
+
+	if err := l.write("delay_on", strconv.FormatInt(on.Milliseconds(), 10)); err != nil {
+		return err
+	}
+
+	return l.write("delay_off", strconv.FormatInt(off.Milliseconds(), 10))
+}
+
+// set sets the trigger and then the brightness, which a trigger change may reset.
+func (l *LED) set(trigger, brightness string) error {
+	if err := l.write("trigger", trigger); err != nil {
+		return err
+	}
+
+	return l.write("brightness", brightness)
+}
+
+// write writes the value to the attribute file of the LED.
+func (l *LED) write(attribute, value string) error {
+	if err := os.WriteFile(filepath.Join(l.dir, attribute), []byte(value), fileMode); err != nil {
+		return fmt.Errorf("failed to set the %s of the LED: %w", attribute, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,84 @@
+package led
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestLED creates the attribute files of a LED under a temporary sysfs root and opens it.
+func newTestLED(t *testing.T) (*LED, string) {
+	t.Helper()
+
+	root := t.TempDir()
+	dir := filepath.Join(root, "green:status")
+
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	for attribute, value := range map[string]string{
+		"trigger": "[none] timer", "brightness": "0", "max_brightness": "255\n", "delay_on": "", "delay_off": "",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, attribute), []byte(value), fileMode); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	led, err := Open(root, "green:status")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return led, dir
+}
+
+// checkAttributes checks the content of the attribute files of the LED.
+func checkAttributes(t *testing.T, dir string, want map[string]string) {
+	t.Helper()
+
+	for attribute, value := range want {
+		content, err := os.ReadFile(filepath.Join(dir, attribute))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(content) != value {
+			t.Errorf("%s = %q, want %q", attribute, content, value)
+		}
+	}
+}
+
+func TestLED(t *testing.T) {
+	t.Parallel()
+
+	led, dir := newTestLED(t)
+
+	if err := led.On(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAttributes(t, dir, map[string]string{"trigger": "none", "brightness": "255"})
+
+	if err := led.Blink(500*time.Millisecond, 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAttributes(t, dir, map[string]string{"trigger": "timer", "delay_on": "500", "delay_off": "1500"})
+
+	if err := led.Off(); err != nil {
+		t.Fatal(err)
+	}
+
+	checkAttributes(t, dir, map[string]string{"trigger": "none", "brightness": "0"})
+}
+
+func TestOpenNotFound(t *testing.T) {
+	t.Parallel()
+
+	if _, err := Open(t.TempDir(), "green:status"); !errors.Is(err, errNotFound) {
+		t.Fatalf("Open() error = %v, want %v", err, errNotFound)
+	}
+}
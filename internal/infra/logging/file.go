@@ -0,0 +1,151 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// filePerm is the mode of the log files, they are not readable by others.
+const filePerm = 0o640
+
+// errInvalidRotation is the error returned when the rotation settings are negative.
+var errInvalidRotation = errors.New("log file size and backups must not be negative")
+
+// RotatingFile is a log file that is rotated when it grows beyond the maximum size.
+//
+// The rotated files are named after the log file with a numeric suffix, the most
+// recent one is "<path>.1". The oldest file is removed once the number of backups
+// is exceeded. The total disk usage is bounded by the maximum size times the number
+// of backups plus one, which suits the small tmpfs of OpenWrt.
+type RotatingFile struct {
+	// path is the path to the current log file.
+	path string
+	// maxSize is the size in bytes after which the file is rotated, zero disables the rotation.
+	maxSize int64
+	// backups is the number of rotated files that are kept.
+	backups int
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// file is the current log file.
+	file *os.File
+	// size is the size of the current log file.
+	size int64
+}
+
+// OpenRotatingFile opens the log file for appending, creating it if it does not exist.
+//
+// Parameters:
+// - path: The path to the log file.
+// - maxSize: The size in bytes after which the file is rotated, zero disables the rotation.
+// - backups: The number of rotated files that are kept.
+//
+// Returns:
+// - *RotatingFile: A pointer to the RotatingFile instance.
+// - error: An error if the settings are invalid or the file cannot be opened.
+func OpenRotatingFile(path string, maxSize int64, backups int) (*RotatingFile, error) {
+	if maxSize < 0 || backups < 0 {
+		return nil, errInvalidRotation
+	}
+
+	file := &RotatingFile{path: path, maxSize: maxSize, backups: backups}
+	if err := file.open(); err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Write writes the event to the log file, rotating the file first if the event does not fit.
+//
+// An event is never split between files, so a file may exceed the maximum size
+// by a single event that is larger than the maximum size.
+func (f *RotatingFile) Write(event []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(event)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.file.Write(event)
+	f.size += int64(n)
+
+	return n, err
+}
+
+// Close closes the current log file.
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.file.Close()
+}
+
+// open opens the log file and reads its current size. The caller must hold the mutex.
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, filePerm)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	f.file, f.size = file, info.Size()
+
+	return nil
+}
+
+// rotate shifts the rotated files, moves the current file to the first backup
+// and opens a new file. The caller must hold the mutex.
+//
+// The new file is opened even if the files cannot be shifted, so that logging goes on.
+func (f *RotatingFile) rotate() error {
+	_ = f.file.Close()
+
+	if err := f.shift(); err != nil {
+		return errors.Join(fmt.Errorf("failed to rotate log file: %w", err), f.open())
+	}
+
+	return f.open()
+}
+
+// shift moves the current file to the first backup and every backup to the next one.
+// The oldest backup is overwritten. The current file is removed if no backups are kept.
+func (f *RotatingFile) shift() error {
+	if f.backups == 0 {
+		return ignoreNotExist(os.Remove(f.path))
+	}
+
+	for index := f.backups - 1; index > 0; index-- {
+		if err := ignoreNotExist(os.Rename(f.backup(index), f.backup(index+1))); err != nil {
+			return err
+		}
+	}
+
+	return ignoreNotExist(os.Rename(f.path, f.backup(1)))
+}
+
+// ignoreNotExist returns nil if the error reports a missing file.
+func ignoreNotExist(err error) error {
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return err
+}
+
+// backup returns the path to the rotated file with the given index.
+func (f *RotatingFile) backup(index int) string {
+	return f.path + "." + strconv.Itoa(index)
+}
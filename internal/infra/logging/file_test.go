@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenRotatingFileInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := OpenRotatingFile(filepath.Join(t.TempDir(), "vakeel.log"), -1, 1)
+	if !errors.Is(err, errInvalidRotation) {
+		t.Fatalf("OpenRotatingFile() error = %v, want %v", err, errInvalidRotation)
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "vakeel.log")
+
+	// The size of an existing file counts towards the first rotation.
+	if err := os.WriteFile(path, []byte("before\n"), filePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := OpenRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Events are never split, so one larger than the limit gets a file of its own.
+	for _, event := range []string{"first\n", "a large event\n", "last\n"} {
+		if _, err := file.Write([]byte(event)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := file.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest backup with "before" was removed.
+	for name, want := range map[string]string{
+		path:           "last\n",
+		file.backup(1): "a large event\n",
+		file.backup(2): "first\n",
+	} {
+		content, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", name, content, want)
+		}
+	}
+
+	if _, err := os.Stat(file.backup(3)); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("the third backup is kept: %v", err)
+	}
+}
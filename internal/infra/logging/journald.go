@@ -0,0 +1,148 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/rs/zerolog"
+)
+
+// JournalSocket is the path to the socket of the native journald protocol.
+const JournalSocket = "/run/systemd/journal/socket"
+
+// journalFieldPrefix is the prefix of the journal fields of the event fields, e.g. VAKEEL_ID.
+const journalFieldPrefix = "VAKEEL_"
+
+// maxJournalFieldName is the maximum length of a journal field name.
+const maxJournalFieldName = 64
+
+// JournalWriter writes the zerolog events to journald with the native protocol,
+// see systemd.journal-fields(7).
+//
+// The message and the level become the MESSAGE and PRIORITY fields. The other
+// fields of the event are upper-cased and prefixed with VAKEEL_, so that the logs can
+// be filtered with e.g. "journalctl VAKEEL_ID=<uuid>". Arrays become repeated fields.
+type JournalWriter struct {
+	// identifier is the SYSLOG_IDENTIFIER of the entries.
+	identifier string
+
+	// mu guards the connection.
+	mu sync.Mutex
+	// conn is the connection to the journald socket.
+	conn net.Conn
+}
+
+// DialJournal connects to the journald socket.
+//
+// Parameters:
+// - path: The path to the journald socket, usually JournalSocket.
+// - identifier: The SYSLOG_IDENTIFIER of the entries.
+//
+// Returns:
+// - *JournalWriter: A pointer to the JournalWriter instance.
+// - error: An error if the socket cannot be connected.
+func DialJournal(path, identifier string) (*JournalWriter, error) {
+	conn, err := net.Dial("unixgram", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald: %w", err)
+	}
+
+	return &JournalWriter{identifier: identifier, conn: conn}, nil
+}
+
+// Write converts a JSON event to a journal entry and sends it to journald.
+func (w *JournalWriter) Write(event []byte) (int, error) {
+	fields, err := decodeEvent(event)
+	if err != nil {
+		return 0, err
+	}
+
+	entry := w.entry(fields)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.conn.Write(entry); err != nil {
+		return 0, err
+	}
+
+	return len(event), nil
+}
+
+// Close closes the connection to the journald socket.
+func (w *JournalWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.conn.Close()
+}
+
+// entry returns the journal entry of the event in the native protocol format.
+func (w *JournalWriter) entry(fields map[string]any) []byte {
+	var entry bytes.Buffer
+
+	message, _ := fields[zerolog.MessageFieldName].(string)
+
+	appendJournalField(&entry, "MESSAGE", message)
+	appendJournalField(&entry, "PRIORITY", strconv.Itoa(severity(fields)))
+	appendJournalField(&entry, "SYSLOG_IDENTIFIER", w.identifier)
+
+	for name, value := range fields {
+		switch name {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName:
+			continue
+		}
+
+		field := journalFieldName(name)
+
+		// Arrays become repeated fields.
+		for _, item := range rawValues(value) {
+			appendJournalField(&entry, field, item)
+		}
+	}
+
+	return entry.Bytes()
+}
+
+// appendJournalField appends the field to the entry.
+//
+// Values with newlines are written in the binary format, i.e. the name, a newline,
+// the little-endian 64-bit length of the value and the value.
+func appendJournalField(entry *bytes.Buffer, name, value string) {
+	if !strings.Contains(value, "\n") {
+		entry.WriteString(name + "=" + value + "\n")
+
+		return
+	}
+
+	entry.WriteString(name + "\n")
+	_ = binary.Write(entry, binary.LittleEndian, uint64(len(value)))
+	entry.WriteString(value + "\n")
+}
+
+// journalFieldName returns the journal field name of the event field, e.g. VAKEEL_ID for "id".
+//
+// Journal field names may only contain upper-case letters, digits and underscores.
+func journalFieldName(name string) string {
+	name = journalFieldPrefix + strings.Map(func(char rune) rune {
+		switch {
+		case char >= 'a' && char <= 'z':
+			return char - 'a' + 'A'
+		case char >= 'A' && char <= 'Z', char >= '0' && char <= '9':
+			return char
+		default:
+			return '_'
+		}
+	}, name)
+
+	if len(name) > maxJournalFieldName {
+		name = name[:maxJournalFieldName]
+	}
+
+	return name
+}
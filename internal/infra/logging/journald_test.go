@@ -0,0 +1,54 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestJournalEntry(t *testing.T) {
+	t.Parallel()
+
+	writer := &JournalWriter{identifier: "vakeel"}
+
+	fields, err := decodeEvent([]byte(`{"level":"warn","event":"connect_failed","retry-in":"1s","id":["a","b"],` +
+		`"message":"retrying"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The fields of the event are written in no particular order.
+	got := strings.Split(strings.TrimSuffix(string(writer.entry(fields)), "\n"), "\n")
+	sort.Strings(got)
+
+	want := []string{
+		"MESSAGE=retrying", "PRIORITY=4", "SYSLOG_IDENTIFIER=vakeel",
+		"VAKEEL_EVENT=connect_failed", "VAKEEL_ID=a", "VAKEEL_ID=b", "VAKEEL_RETRY_IN=1s",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("entry() = %q, want %q", got, want)
+	}
+}
+
+func TestAppendJournalFieldMultiline(t *testing.T) {
+	t.Parallel()
+
+	value := "first\nsecond"
+
+	var want bytes.Buffer
+
+	want.WriteString("MESSAGE\n")
+	_ = binary.Write(&want, binary.LittleEndian, uint64(len(value)))
+	want.WriteString(value + "\n")
+
+	var got bytes.Buffer
+
+	appendJournalField(&got, "MESSAGE", value)
+
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Fatalf("appendJournalField() = %q, want %q", got.Bytes(), want.Bytes())
+	}
+}
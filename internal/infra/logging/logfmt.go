@@ -0,0 +1,142 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/rs/zerolog"
+)
+
+// messageKey is the logfmt key of the message.
+const messageKey = "msg"
+
+// LogfmtWriter converts the JSON events of zerolog to logfmt lines, e.g.
+//
+//	time=2024-01-02T15:04:05Z level=info msg="agent stopped"
+//
+// The time, the level, the message and the error come first, the other fields
+// follow in alphabetical order. The message is written with the conventional "msg" key.
+type LogfmtWriter struct {
+	// out is the destination of the logfmt lines.
+	out io.Writer
+}
+
+// NewLogfmtWriter creates a new LogfmtWriter instance.
+//
+// Parameters:
+// - out: The destination of the logfmt lines.
+//
+// Returns:
+// - *LogfmtWriter: A pointer to the LogfmtWriter instance.
+func NewLogfmtWriter(out io.Writer) *LogfmtWriter {
+	return &LogfmtWriter{out: out}
+}
+
+// Write converts a JSON event to a logfmt line and writes it to the destination.
+//
+// It returns the length of the event, so that zerolog does not report a short write.
+func (w *LogfmtWriter) Write(event []byte) (int, error) {
+	fields, err := decodeEvent(event)
+	if err != nil {
+		return 0, err
+	}
+
+	var line strings.Builder
+
+	// Write the well-known fields first.
+	for _, field := range []struct{ name, key string }{
+		{zerolog.TimestampFieldName, zerolog.TimestampFieldName},
+		{zerolog.LevelFieldName, zerolog.LevelFieldName},
+		{zerolog.MessageFieldName, messageKey},
+		{zerolog.ErrorFieldName, zerolog.ErrorFieldName},
+	} {
+		if value, ok := fields[field.name]; ok {
+			writePair(&line, field.key, value)
+			delete(fields, field.name)
+		}
+	}
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		writePair(&line, name, fields[name])
+	}
+
+	line.WriteByte('\n')
+
+	if _, err := io.WriteString(w.out, line.String()); err != nil {
+		return 0, err
+	}
+
+	return len(event), nil
+}
+
+// decodeEvent decodes the fields of a JSON event of zerolog.
+//
+// Numbers are kept as json.Number, so that they are written exactly as zerolog formatted them.
+func decodeEvent(event []byte) (map[string]any, error) {
+	decoder := json.NewDecoder(bytes.NewReader(event))
+	decoder.UseNumber()
+
+	var fields map[string]any
+	if err := decoder.Decode(&fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// writePair appends the key=value pair to the line, separated from the previous pair by a space.
+func writePair(line *strings.Builder, name string, value any) {
+	if line.Len() > 0 {
+		line.WriteByte(' ')
+	}
+
+	line.WriteString(name)
+	line.WriteByte('=')
+	line.WriteString(formatValue(value))
+}
+
+// formatValue returns the logfmt representation of a JSON value.
+//
+// Strings are quoted if they are empty or contain spaces, quotes, "=" or control characters.
+// Objects and arrays are written as quoted JSON.
+func formatValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return quote(value)
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		content, err := json.Marshal(value)
+		if err != nil {
+			return quote(err.Error())
+		}
+
+		return quote(string(content))
+	}
+}
+
+// quote quotes the string if it cannot be written as a bare logfmt value.
+func quote(value string) string {
+	if value == "" || strings.ContainsFunc(value, func(char rune) bool {
+		return char <= ' ' || char == '=' || char == '"' || char == '\\' || char == 0x7f
+	}) {
+		return strconv.Quote(value)
+	}
+
+	return value
+}
@@ -0,0 +1,91 @@
+package logging
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/rs/zerolog"
+)
+
+// Sinks of the log messages.
+const (
+	// SinkStdout writes the messages to the standard output or the log file.
+	SinkStdout = "stdout"
+	// SinkSyslog writes the messages to the local syslog socket in the RFC 5424 format.
+	SinkSyslog = "syslog"
+	// SinkJournald writes the messages to journald with the native protocol.
+	SinkJournald = "journald"
+)
+
+// Severities of the syslog protocol, see RFC 5424, section 6.2.1.
+const (
+	severityEmergency = 0
+	severityCritical  = 2
+	severityError     = 3
+	severityWarning   = 4
+	severityInfo      = 6
+	severityDebug     = 7
+)
+
+// severity returns the syslog severity of the zerolog level of the event.
+func severity(fields map[string]any) int {
+	value, _ := fields[zerolog.LevelFieldName].(string)
+
+	level, err := zerolog.ParseLevel(value)
+	if err != nil {
+		return severityInfo
+	}
+
+	switch level {
+	case zerolog.PanicLevel:
+		return severityEmergency
+	case zerolog.FatalLevel:
+		return severityCritical
+	case zerolog.ErrorLevel:
+		return severityError
+	case zerolog.WarnLevel:
+		return severityWarning
+	case zerolog.DebugLevel, zerolog.TraceLevel:
+		return severityDebug
+	default:
+		return severityInfo
+	}
+}
+
+// rawValues returns the plain string representations of a JSON value.
+//
+// Every element of an array is a separate value, so that the sinks can repeat the field.
+// Objects are written as JSON.
+func rawValues(value any) []string {
+	if values, ok := value.([]any); ok {
+		result := make([]string, 0, len(values))
+		for _, item := range values {
+			result = append(result, rawValue(item))
+		}
+
+		return result
+	}
+
+	return []string{rawValue(value)}
+}
+
+// rawValue returns the plain string representation of a JSON value.
+func rawValue(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		content, err := json.Marshal(value)
+		if err != nil {
+			return err.Error()
+		}
+
+		return string(content)
+	}
+}
@@ -0,0 +1,210 @@
+package logging
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// SyslogSocket is the path to the local syslog socket.
+const SyslogSocket = "/dev/log"
+
+// facilityDaemon is the syslog facility of system daemons, see RFC 5424, section 6.2.1.
+const facilityDaemon = 3
+
+// syslogTimeFormat is the timestamp format of RFC 5424 with microsecond precision.
+const syslogTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// structuredDataID is the SD-ID of the structured data of the messages.
+// 32473 is the private enterprise number reserved for documentation by RFC 5612.
+const structuredDataID = "vakeel@32473"
+
+// maxParamName is the maximum length of an SD-PARAM name, see RFC 5424, section 6.3.3.
+const maxParamName = 32
+
+// eventField is the name of the event field with the event type of the message.
+const eventField = "event"
+
+// nilValue is the value of an empty header field of RFC 5424.
+const nilValue = "-"
+
+// SyslogWriter writes the zerolog events to the local syslog socket as RFC 5424 messages.
+//
+// The event type becomes the MSGID of the message, and the fields of the event
+// other than the time, the level and the message become the structured data,
+// e.g. [vakeel@32473 id="..." target="..." event="update"].
+type SyslogWriter struct {
+	// path is the path to the syslog socket.
+	path string
+	// appName is the APP-NAME of the messages.
+	appName string
+	// hostname is the HOSTNAME of the messages.
+	hostname string
+
+	// mu guards the connection.
+	mu sync.Mutex
+	// conn is the connection to the syslog socket.
+	conn net.Conn
+}
+
+// DialSyslog connects to the syslog socket.
+//
+// Parameters:
+// - path: The path to the syslog socket, usually SyslogSocket.
+// - appName: The APP-NAME of the messages.
+//
+// Returns:
+// - *SyslogWriter: A pointer to the SyslogWriter instance.
+// - error: An error if the socket cannot be connected.
+func DialSyslog(path, appName string) (*SyslogWriter, error) {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = nilValue
+	}
+
+	writer := &SyslogWriter{path: path, appName: appName, hostname: hostname}
+	if err := writer.connect(); err != nil {
+		return nil, err
+	}
+
+	return writer, nil
+}
+
+// Write converts a JSON event to an RFC 5424 message and sends it to the syslog socket.
+//
+// The socket is reconnected once if sending fails, e.g. after the syslog daemon restarted.
+func (w *SyslogWriter) Write(event []byte) (int, error) {
+	fields, err := decodeEvent(event)
+	if err != nil {
+		return 0, err
+	}
+
+	message := []byte(w.format(fields, time.Now()))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := w.conn.Write(message); err != nil {
+		if err := w.connect(); err != nil {
+			return 0, err
+		}
+
+		if _, err := w.conn.Write(message); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(event), nil
+}
+
+// Close closes the connection to the syslog socket.
+func (w *SyslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.conn.Close()
+}
+
+// connect (re)connects to the syslog socket. The caller must hold the mutex unless it is the constructor.
+func (w *SyslogWriter) connect() error {
+	if w.conn != nil {
+		_ = w.conn.Close()
+	}
+
+	conn, err := net.Dial("unixgram", w.path)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+
+	w.conn = conn
+
+	return nil
+}
+
+// format returns the RFC 5424 message of the event.
+func (w *SyslogWriter) format(fields map[string]any, now time.Time) string {
+	message, _ := fields[zerolog.MessageFieldName].(string)
+
+	msgID := nilValue
+	if event, ok := fields[eventField].(string); ok && event != "" {
+		msgID = event
+	}
+
+	priority := facilityDaemon*8 + severity(fields) //nolint:mnd
+
+	return fmt.Sprintf("<%d>1 %s %s %s %d %s %s %s",
+		priority,
+		now.Format(syslogTimeFormat),
+		w.hostname,
+		w.appName,
+		os.Getpid(),
+		msgID,
+		structuredData(fields),
+		message,
+	)
+}
+
+// structuredData returns the structured data element with the fields of the event
+// other than the time, the level and the message, or "-" if there are none.
+func structuredData(fields map[string]any) string {
+	names := make([]string, 0, len(fields))
+
+	for name := range fields {
+		switch name {
+		case zerolog.TimestampFieldName, zerolog.LevelFieldName, zerolog.MessageFieldName:
+		default:
+			names = append(names, name)
+		}
+	}
+
+	if len(names) == 0 {
+		return nilValue
+	}
+
+	sort.Strings(names)
+
+	var data strings.Builder
+
+	data.WriteString("[" + structuredDataID)
+
+	for _, name := range names {
+		param := paramName(name)
+
+		// Arrays become repeated parameters.
+		for _, value := range rawValues(fields[name]) {
+			data.WriteString(" " + param + `="` + escapeParamValue(value) + `"`)
+		}
+	}
+
+	data.WriteString("]")
+
+	return data.String()
+}
+
+// paramName returns the SD-PARAM name of the field, with the unsupported characters replaced by "_".
+func paramName(name string) string {
+	name = strings.Map(func(char rune) rune {
+		if char <= ' ' || char > '~' || char == '=' || char == ']' || char == '"' {
+			return '_'
+		}
+
+		return char
+	}, name)
+
+	if len(name) > maxParamName {
+		name = name[:maxParamName]
+	}
+
+	return name
+}
+
+// escapeParamValue escapes the characters of the SD-PARAM value that must be escaped, see RFC 5424, section 6.3.3.
+func escapeParamValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
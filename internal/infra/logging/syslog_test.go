@@ -0,0 +1,46 @@
+package logging
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormat(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 16, 20, 52, 14, 123456789, time.UTC)
+	writer := &SyslogWriter{appName: "vakeel", hostname: "router"}
+	header := " 2026-10-16T20:52:14.123456Z router vakeel " + strconv.Itoa(os.Getpid()) + " "
+
+	tests := []struct {
+		event string
+		want  string
+	}{
+		{
+			event: `{"level":"info","time":"2026-10-16T20:52:14Z","message":"agent started"}`,
+			want:  "<30>1" + header + "- - agent started",
+		},
+		{
+			event: `{"level":"warn","event":"connect_failed","target":"vakeel:4643","attempt":3,"message":"retrying"}`,
+			want: "<28>1" + header + `connect_failed [vakeel@32473 attempt="3" event="connect_failed" ` +
+				`target="vakeel:4643"] retrying`,
+		},
+		{
+			event: `{"level":"error","error":"say \"hi\" [x] \\ y","message":"failed"}`,
+			want:  "<27>1" + header + `- [vakeel@32473 error="say \"hi\" [x\] \\ y"] failed`,
+		},
+	}
+
+	for _, tt := range tests {
+		fields, err := decodeEvent([]byte(tt.event))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := writer.format(fields, now); got != tt.want {
+			t.Errorf("format(%s) = %q, want %q", tt.event, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+	"github.com/rs/zerolog"
+)
+
+// Formats of the log output.
+const (
+	// FormatConsole is the human-readable format, colored on terminals.
+	FormatConsole = "console"
+	// FormatJSON is the JSON format of zerolog, one object per line.
+	FormatJSON = "json"
+	// FormatLogfmt is the key=value format understood by most log processors.
+	FormatLogfmt = "logfmt"
+)
+
+// noColorEnv is the environment variable that disables colors, see https://no-color.org.
+const noColorEnv = "NO_COLOR"
+
+// errUnknownFormat is the error returned when the log format is not supported.
+var errUnknownFormat = errors.New("log format must be console, json or logfmt")
+
+// NewWriter creates the writer that formats the zerolog events.
+//
+// Parameters:
+// - format: The format of the log output: console, json or logfmt.
+// - out: The destination of the formatted events.
+//
+// Returns:
+// - io.Writer: The writer of the zerolog events.
+// - error: An error if the format is not supported.
+func NewWriter(format string, out io.Writer) (io.Writer, error) {
+	switch format {
+	case FormatConsole:
+		return zerolog.NewConsoleWriter(func(w *zerolog.ConsoleWriter) {
+			w.Out = out
+			w.TimeFormat = time.RFC3339Nano
+			w.NoColor = !IsTerminal(out)
+		}), nil
+	case FormatJSON:
+		return out, nil
+	case FormatLogfmt:
+		return NewLogfmtWriter(out), nil
+	default:
+		return nil, fmt.Errorf("%w, got %q", errUnknownFormat, format)
+	}
+}
+
+// IsTerminal reports whether the writer is a terminal that supports colors.
+//
+// Colors are disabled when the NO_COLOR environment variable is set, so that
+// the escape codes do not end up in journald or logread.
+func IsTerminal(out io.Writer) bool {
+	if os.Getenv(noColorEnv) != "" {
+		return false
+	}
+
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+
+	return isatty.IsTerminal(file.Fd()) || isatty.IsCygwinTerminal(file.Fd())
+}
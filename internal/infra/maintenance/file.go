@@ -0,0 +1,125 @@
+// Package maintenance persists the pauses of the update requests of the agent,
+// so that a maintenance window survives restarts of the agent.
+package maintenance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/bavix/vakeel/pkg/atomicfile"
+)
+
+// fileName is the name of the state file in the state directory.
+const fileName = "pause.json"
+
+// fileMode is the file mode of the state file.
+const fileMode = 0o644
+
+// Pause is a pause of the update requests of an agent ID.
+type Pause struct {
+	// ID is the paused agent ID, uuid.Nil pauses all the agent IDs.
+	ID uuid.UUID `json:"id"`
+	// Until is the time the update requests are sent again.
+	Until time.Time `json:"until"`
+}
+
+// state is the content of the state file.
+type state struct {
+	// Pauses are the pauses in effect.
+	Pauses []Pause `json:"pauses"`
+}
+
+// File is the state file with the pauses of the update requests.
+type File struct {
+	// path is the path to the state file.
+	path string
+}
+
+// NewFile creates the state file in the given state directory.
+//
+// Parameters:
+// - stateDir: The directory where the agent keeps its persistent state.
+// - instance: The name of the agent instance, it is appended to the file name, e.g. "pause-main.json".
+//
+// Returns:
+// - *File: A pointer to the File instance.
+func NewFile(stateDir, instance string) *File {
+	name := fileName
+	if instance != "" {
+		name = strings.TrimSuffix(fileName, filepath.Ext(fileName)) + "-" + instance + filepath.Ext(fileName)
+	}
+
+	return &File{path: filepath.Join(stateDir, name)}
+}
+
+// Path returns the path to the state file.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Load reads the pauses that have not ended at the given time.
+// No pauses are returned if the state file does not exist.
+//
+// Parameters:
+// - now: The current time.
+//
+// Returns:
+// - []Pause: The pauses in effect.
+// - error: An error if the state file cannot be read.
+func (f *File) Load(now time.Time) ([]Pause, error) {
+	content, err := os.ReadFile(f.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var saved state
+	if err := json.Unmarshal(content, &saved); err != nil {
+		return nil, fmt.Errorf("invalid state file %s: %w", f.path, err)
+	}
+
+	pauses := make([]Pause, 0, len(saved.Pauses))
+
+	for _, pause := range saved.Pauses {
+		if now.Before(pause.Until) {
+			pauses = append(pauses, pause)
+		}
+	}
+
+	return pauses, nil
+}
+
+// Save writes the pauses to the state file, the file is removed if there are none.
+//
+// Parameters:
+// - pauses: The pauses in effect.
+//
+// Returns:
+// - error: An error if the state file cannot be written.
+func (f *File) Save(pauses []Pause) error {
+	if len(pauses) == 0 {
+		if err := os.Remove(f.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+
+		return nil
+	}
+
+	content, err := json.MarshalIndent(state{Pauses: pauses}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.WriteFile(f.path, append(content, '\n'), fileMode)
+}
@@ -0,0 +1,46 @@
+package maintenance
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestFile(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	id := uuid.MustParse("224f8a59-6705-4f3e-b7de-177757932aad")
+	file := NewFile(t.TempDir(), "main")
+
+	// Pauses that ended while the agent was stopped are dropped on load.
+	if err := file.Save([]Pause{{ID: uuid.Nil, Until: now}, {ID: id, Until: now.Add(time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+
+	pauses, err := file.Load(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(pauses) != 1 || pauses[0].ID != id || !pauses[0].Until.Equal(now.Add(time.Hour)) {
+		t.Fatalf("Load() = %v, want the pause of %s", pauses, id)
+	}
+
+	// Saving no pauses removes the state file.
+	if err := file.Save(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(file.Path()); !errors.Is(err, fs.ErrNotExist) {
+		t.Fatalf("the state file is kept: %v", err)
+	}
+
+	if pauses, err := file.Load(now); err != nil || len(pauses) != 0 {
+		t.Fatalf("Load() without a state file = %v, %v", pauses, err)
+	}
+}
@@ -0,0 +1,228 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc/status"
+)
+
+// Path is the HTTP path of the metrics endpoint.
+const Path = "/metrics"
+
+// namespace is the prefix of the metric names.
+const namespace = "vakeel"
+
+// shutdownTimeout is the maximum time to wait for the pending scrapes when the server stops.
+const shutdownTimeout = 5 * time.Second
+
+// readHeaderTimeout limits the time to read the request headers of a scrape.
+const readHeaderTimeout = 10 * time.Second
+
+// Metrics records the activity of the agent and exposes it in the Prometheus format.
+//
+// It implements the app.Metrics interface.
+type Metrics struct {
+	// registry holds the collectors of the agent.
+	registry *prometheus.Registry
+	// heartbeats counts the update requests sent.
+	heartbeats prometheus.Counter
+	// errors counts the failed stream opens and sends by gRPC code.
+	errors *prometheus.CounterVec
+	// reconnects counts the reconnection attempts.
+	reconnects prometheus.Counter
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// openedAt is the time the current stream was opened, zero if there is no stream.
+	openedAt time.Time
+	// lastSend is the time of the last successful send, or the start of the agent before the first one.
+	lastSend time.Time
+}
+
+// New creates a new Metrics instance and registers its collectors.
+//
+// Returns:
+// - *Metrics: A pointer to the Metrics instance.
+func New() *Metrics {
+	metrics := &Metrics{
+		registry: prometheus.NewRegistry(),
+		heartbeats: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "heartbeats_sent_total",
+			Help:      "Number of update requests sent to the server.",
+		}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "send_errors_total",
+			Help:      "Number of failed attempts to open a stream or send an update request, by gRPC code.",
+		}, []string{"code"}),
+		reconnects: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconnects_total",
+			Help:      "Number of reconnection attempts to the server.",
+		}),
+		lastSend: time.Now(),
+	}
+
+	metrics.registry.MustRegister(
+		metrics.heartbeats,
+		metrics.errors,
+		metrics.reconnects,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "stream_age_seconds",
+			Help:      "Age of the current stream to the server, zero if there is no stream.",
+		}, metrics.streamAge),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "last_send_age_seconds",
+			Help:      "Time since the last successful update request, or since the start before the first one.",
+		}, metrics.lastSendAge),
+		buildInfo(),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+
+	return metrics
+}
+
+// StreamOpened records that a stream to the server has been opened.
+func (m *Metrics) StreamOpened() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.openedAt = time.Now()
+}
+
+// StreamClosed records that the stream has been closed.
+func (m *Metrics) StreamClosed() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.openedAt = time.Time{}
+}
+
+// Reconnecting records a reconnection attempt.
+func (m *Metrics) Reconnecting() {
+	m.reconnects.Inc()
+}
+
+// HeartbeatSent records a successful update request.
+func (m *Metrics) HeartbeatSent() {
+	m.heartbeats.Inc()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lastSend = time.Now()
+}
+
+// SendFailed records a failed attempt to open a stream or send an update request.
+func (m *Metrics) SendFailed(err error) {
+	m.errors.WithLabelValues(status.Code(err).String()).Inc()
+}
+
+// Handler returns the HTTP handler of the metrics endpoint.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Serve serves the metrics endpoint on the listener until the context is cancelled.
+//
+// Parameters:
+// - ctx: The context that stops the server.
+// - listener: The listener of the HTTP server.
+//
+// Returns:
+// - error: An error if the server fails, nil once it has been stopped.
+func (m *Metrics) Serve(ctx context.Context, listener net.Listener) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, m.Handler())
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	// Stop the server once the context is cancelled.
+	stopped := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		defer close(stopped)
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		_ = server.Shutdown(shutdownCtx)
+	})
+
+	err := server.Serve(listener)
+	if errors.Is(err, http.ErrServerClosed) {
+		<-stopped
+
+		return nil
+	}
+
+	stop()
+
+	return err
+}
+
+// streamAge returns the age of the current stream in seconds.
+func (m *Metrics) streamAge() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.openedAt.IsZero() {
+		return 0
+	}
+
+	return time.Since(m.openedAt).Seconds()
+}
+
+// lastSendAge returns the time since the last successful update request in seconds.
+func (m *Metrics) lastSendAge() float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return time.Since(m.lastSend).Seconds()
+}
+
+// buildInfo returns the gauge with the version of the agent in its labels.
+func buildInfo() prometheus.Collector {
+	version, revision := "unknown", "unknown"
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		version = info.Main.Version
+
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				revision = setting.Value
+			}
+		}
+	}
+
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "build_info",
+		Help:      "Build information of the agent, the value is always 1.",
+		ConstLabels: prometheus.Labels{
+			"version":   version,
+			"revision":  revision,
+			"goversion": runtime.Version(),
+		},
+	})
+	gauge.Set(1)
+
+	return gauge
+}
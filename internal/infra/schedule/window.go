@@ -0,0 +1,79 @@
+// Package schedule parses the cron expressions of the windows in which the agent IDs send update requests.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	// Embed the time zone database, the OpenWrt images ship without /usr/share/zoneinfo.
+	_ "time/tzdata"
+
+	"github.com/robfig/cron/v3"
+)
+
+// errDescriptor is the error returned for the descriptors such as @daily, which only match a single minute
+// and have no duration, and for @every, which is a delay rather than a window.
+var errDescriptor = errors.New("descriptors such as @daily are not supported, use a cron expression, e.g. \"* 0-5 * * *\"")
+
+// parser parses the standard cron expressions with five fields.
+// The expressions may start with CRON_TZ=<zone> or TZ=<zone> to be evaluated in that time zone.
+//
+//nolint:gochecknoglobals
+var parser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow)
+
+// Window is a recurring time window given by a cron expression.
+//
+// A time is within the window if the expression matches its minute, e.g. "* 9-17 * * MON-FRI"
+// is the business hours from 9:00 to 17:59 on weekdays and "CRON_TZ=Europe/Berlin * 1-3 * * *"
+// is the night from 1:00 to 3:59 in Berlin. Without a time zone the local time is used.
+type Window struct {
+	// expression is the cron expression of the window.
+	expression string
+	// schedule matches the minutes of the window.
+	schedule cron.Schedule
+}
+
+// Parse parses the cron expression of a window.
+//
+// Parameters:
+// - expression: The cron expression, optionally prefixed with CRON_TZ=<zone>.
+//
+// Returns:
+// - *Window: A pointer to the Window instance.
+// - error: An error if the expression is invalid.
+func Parse(expression string) (*Window, error) {
+	if isDescriptor(expression) {
+		return nil, fmt.Errorf("invalid schedule %q: %w", expression, errDescriptor)
+	}
+
+	schedule, err := parser.Parse(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", expression, err)
+	}
+
+	return &Window{expression: expression, schedule: schedule}, nil
+}
+
+// isDescriptor reports whether the expression is a descriptor such as @daily, after the optional time zone.
+func isDescriptor(expression string) bool {
+	fields := strings.Fields(expression)
+	if len(fields) > 0 && (strings.HasPrefix(fields[0], "CRON_TZ=") || strings.HasPrefix(fields[0], "TZ=")) {
+		fields = fields[1:]
+	}
+
+	return len(fields) > 0 && strings.HasPrefix(fields[0], "@")
+}
+
+// Active reports whether the time is within the window.
+func (w *Window) Active(t time.Time) bool {
+	minute := t.Truncate(time.Minute)
+
+	// The minute matches if it is the next activation right before it.
+	return w.schedule.Next(minute.Add(-time.Nanosecond)).Equal(minute)
+}
+
+// String returns the cron expression of the window.
+func (w *Window) String() string {
+	return w.expression
+}
@@ -0,0 +1,55 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, expression := range []string{"0 * 9-17 * * MON-FRI", "* 25 * * *", "CRON_TZ=Nowhere/City * * * * *"} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Parse(%q) succeeded", expression)
+		}
+	}
+
+	for _, expression := range []string{"@every 1h", "@daily", "@hourly", "CRON_TZ=Europe/Berlin @midnight"} {
+		if _, err := Parse(expression); !errors.Is(err, errDescriptor) {
+			t.Errorf("Parse(%q) error = %v, want %v", expression, err, errDescriptor)
+		}
+	}
+}
+
+func TestWindowActive(t *testing.T) {
+	t.Parallel()
+
+	// 2026-10-16 is a Friday.
+	friday := time.Date(2026, 10, 16, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		at         time.Time
+		want       bool
+	}{
+		{expression: "* 9-17 * * MON-FRI", at: friday.Add(9 * time.Hour), want: true},
+		{expression: "* 9-17 * * MON-FRI", at: friday.Add(17*time.Hour + 59*time.Minute + 59*time.Second), want: true},
+		{expression: "* 9-17 * * MON-FRI", at: friday.Add(18 * time.Hour), want: false},
+		{expression: "* 9-17 * * MON-FRI", at: friday.Add(34 * time.Hour), want: false},
+		// 01:30 in Berlin is 23:30 UTC of the previous day in the summer time.
+		{expression: "CRON_TZ=Europe/Berlin * 1-3 * * *", at: friday.Add(-30 * time.Minute), want: true},
+		{expression: "CRON_TZ=Europe/Berlin * 1-3 * * *", at: friday.Add(150 * time.Minute), want: false},
+	}
+
+	for _, tt := range tests {
+		window, err := Parse(tt.expression)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := window.Active(tt.at); got != tt.want {
+			t.Errorf("%q.Active(%v) = %v, want %v", tt.expression, tt.at, got, tt.want)
+		}
+	}
+}
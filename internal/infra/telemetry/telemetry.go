@@ -0,0 +1,125 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetricgrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/stats"
+)
+
+// serviceName is the name of the service in the exported telemetry.
+const serviceName = "vakeel"
+
+// instrumentationName is the name of the tracer of the agent.
+const instrumentationName = "github.com/bavix/vakeel"
+
+// errNoEndpoint is the error returned when the OTLP endpoint is not configured.
+var errNoEndpoint = errors.New("OTLP endpoint is required")
+
+// Options holds the settings of the OTLP export.
+type Options struct {
+	// Endpoint is the address of the OTLP gRPC receiver, e.g. "localhost:4317".
+	Endpoint string
+	// Insecure disables TLS on the connection to the receiver.
+	Insecure bool
+	// InstanceID identifies the agent among the instances of the service, e.g. its first agent ID.
+	InstanceID string
+}
+
+// Telemetry exports the traces and the metrics of the agent over OTLP.
+type Telemetry struct {
+	// tracerProvider creates the tracers and exports the spans.
+	tracerProvider *sdktrace.TracerProvider
+	// meterProvider creates the meters and exports the metrics.
+	meterProvider *sdkmetric.MeterProvider
+}
+
+// New creates the tracer and meter providers that export to the OTLP gRPC receiver.
+//
+// The exporters connect lazily, so an unavailable receiver does not prevent the agent from starting.
+// The standard OTEL_EXPORTER_OTLP_* environment variables are honoured for the settings that are
+// not configured, e.g. the headers.
+//
+// Parameters:
+// - ctx: The context of the exporters.
+// - opts: The settings of the OTLP export.
+//
+// Returns:
+// - *Telemetry: A pointer to the Telemetry instance.
+// - error: An error if the exporters cannot be created.
+func New(ctx context.Context, opts Options) (*Telemetry, error) {
+	if opts.Endpoint == "" {
+		return nil, errNoEndpoint
+	}
+
+	traceOptions := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(opts.Endpoint)}
+	metricOptions := []otlpmetricgrpc.Option{otlpmetricgrpc.WithEndpoint(opts.Endpoint)}
+
+	if opts.Insecure {
+		traceOptions = append(traceOptions, otlptracegrpc.WithInsecure())
+		metricOptions = append(metricOptions, otlpmetricgrpc.WithInsecure())
+	}
+
+	traceExporter, err := otlptracegrpc.New(ctx, traceOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP trace exporter: %w", err)
+	}
+
+	metricExporter, err := otlpmetricgrpc.New(ctx, metricOptions...)
+	if err != nil {
+		return nil, errors.Join(fmt.Errorf("failed to create OTLP metric exporter: %w", err), traceExporter.Shutdown(ctx))
+	}
+
+	res := resource.NewWithAttributes(
+		semconv.SchemaURL,
+		semconv.ServiceName(serviceName),
+		semconv.ServiceInstanceID(opts.InstanceID),
+	)
+
+	return &Telemetry{
+		tracerProvider: sdktrace.NewTracerProvider(
+			sdktrace.WithBatcher(traceExporter),
+			sdktrace.WithResource(res),
+		),
+		meterProvider: sdkmetric.NewMeterProvider(
+			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
+			sdkmetric.WithResource(res),
+		),
+	}, nil
+}
+
+// Tracer returns the tracer of the agent.
+func (t *Telemetry) Tracer() trace.Tracer {
+	return t.tracerProvider.Tracer(instrumentationName)
+}
+
+// StatsHandler returns the gRPC client instrumentation that records a span and
+// the RPC metrics of every call.
+func (t *Telemetry) StatsHandler() stats.Handler {
+	return otelgrpc.NewClientHandler(
+		otelgrpc.WithTracerProvider(t.tracerProvider),
+		otelgrpc.WithMeterProvider(t.meterProvider),
+		otelgrpc.WithPropagators(propagation.TraceContext{}),
+	)
+}
+
+// Shutdown flushes the pending spans and metrics and stops the exporters.
+//
+// Parameters:
+// - ctx: The context that limits the time to flush.
+//
+// Returns:
+// - error: An error if the telemetry cannot be flushed.
+func (t *Telemetry) Shutdown(ctx context.Context) error {
+	return errors.Join(t.tracerProvider.Shutdown(ctx), t.meterProvider.Shutdown(ctx))
+}
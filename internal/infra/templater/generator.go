@@ -2,17 +2,20 @@ package templater
 
 import (
 	"bytes"
+	"context"
 	_ "embed"
 	"errors"
 	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
 	"text/template"
 
-	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 
 	"github.com/bavix/vakeel/pkg/featnix"
+	"github.com/bavix/vakeel/pkg/uci"
 )
 
 // errUnsupportedOS is the error returned when the operating system is unsupported.
@@ -38,22 +41,44 @@ const openwrtServicePath = "/etc/init.d/vakeel"
 // when the agent crashes or is terminated, and other options.
 const systemdServicePath = "/etc/systemd/system/vakeel.service"
 
+// openwrtConfigPath is the path to the UCI configuration of the vakeel agents
+// on OpenWRT systems.
+//
+// The init script starts one agent for every "agent" section of the configuration.
+// The file is only generated if it does not exist, so that the changes made with uci are kept.
+// Otherwise only the server, the port and the IDs of the section written by register are updated.
+const openwrtConfigPath = "/etc/config/vakeel"
+
+// uciSection is the name of the agent section of the UCI configuration written by register.
+const uciSection = "main"
+
 //go:embed openwrt.stub
 var openwrtTemplate string
 
 //go:embed systemd.stub
 var systemdTemplate string
 
+//go:embed uci.stub
+var uciTemplate string
+
 // Data contains the data used to fill the stub agent template.
 type Data struct {
 	// AppPath is the path to the application binary.
 	AppPath string
-	// ID is the ID of the agent.
-	ID uuid.UUID
+	// IDs are the IDs of the agent in the "<uuid>[=<label>]" format.
+	IDs []string
 	// Host is the hostname of the vakeel server.
 	Host string
 	// Port is the port of the vakeel server.
 	Port int
+	// Args are the additional arguments of the agent command, e.g. the transport security flags.
+	Args []string
+	// Config is the path to the configuration file of the agent.
+	// If it is set, the settings are read from the file instead of the command line.
+	Config string
+	// Token is the auth token of the agent.
+	// It is passed outside of the command line, so it does not show up in the process list.
+	Token string
 }
 
 // ServiceGenerator is a template for creating stub agents.
@@ -61,25 +86,31 @@ type Data struct {
 // It contains the data used to fill the stub agent template.
 type ServiceGenerator struct {
 	// context contains the context used to fill the stub agent template.
-	// It contains the path to the application binary, the ID of the agent,
+	// It contains the path to the application binary, the IDs of the agent,
 	// the hostname of the vakeel server, and the port of the vakeel server.
 	context Data
 }
 
-// New creates a new ServiceGenerator instance with the given ID, host, and port.
+// New creates a new ServiceGenerator instance with the given IDs, host, port, and arguments.
 //
 // Parameters:
-// - id: The ID of the agent.
+// - ids: The IDs of the agent in the "<uuid>[=<label>]" format.
 // - host: The hostname of the vakeel server.
 // - port: The port of the vakeel server.
+// - args: The additional arguments of the agent command.
+// - token: The auth token of the agent, empty if the agent does not authenticate.
+// - config: The path to the configuration file of the agent, empty if the agent has none.
 //
 // Returns:
 // - *ServiceGenerator: A pointer to the ServiceGenerator instance.
 // - error: An error if any.
 func New(
-	id uuid.UUID, // The ID of the agent.
+	ids []string, // The IDs of the agent.
 	host string, // The hostname of the vakeel server.
 	port int, // The port of the vakeel server.
+	args []string, // The additional arguments of the agent command.
+	token string, // The auth token of the agent.
+	config string, // The path to the configuration file of the agent.
 ) (*ServiceGenerator, error) {
 	// Get the path to the application binary.
 	//
@@ -90,14 +121,17 @@ func New(
 		return nil, err // Return the error if any.
 	}
 
-	// Create a new ServiceGenerator instance with the given ID, host, port, and appPath.
+	// Create a new ServiceGenerator instance with the given IDs, host, port, and appPath.
 	return &ServiceGenerator{
-		// Initialize the context with the given ID, host, port, and appPath.
+		// Initialize the context with the given IDs, host, port, and appPath.
 		context: Data{
 			AppPath: appPath, // The path to the application binary.
-			ID:      id,      // The ID of the agent.
+			IDs:     ids,     // The IDs of the agent.
 			Host:    host,    // The hostname of the vakeel server.
 			Port:    port,    // The port of the vakeel server.
+			Args:    args,    // The additional arguments of the agent command.
+			Token:   token,   // The auth token of the agent.
+			Config:  config,  // The path to the configuration file of the agent.
 		},
 	}, nil
 }
@@ -111,7 +145,7 @@ func New(
 // systemd, it returns nil.
 //
 // The stub agent template is a Go template that is used to create stub agents.
-// The template contains placeholders for the application binary path, agent ID,
+// The template contains placeholders for the application binary path, agent IDs,
 // the hostname of the vakeel server, and the port of the vakeel server.
 //
 // Returns:
@@ -150,8 +184,8 @@ func (t *ServiceGenerator) stub() *string {
 //	error: An error if the operating system is unsupported or if there is an
 //	error enabling or starting the service. Nil if the registration is
 //	successful.
-func (t *ServiceGenerator) Register() error {
-	if _, err := t.generate(); err != nil {
+func (t *ServiceGenerator) Register(ctx context.Context) error {
+	if _, err := t.generate(ctx); err != nil {
 		return err
 	}
 
@@ -249,7 +283,7 @@ func (t *ServiceGenerator) registerOpenWrt() error {
 //
 //	string: The generated stub agent service file.
 //	error:  An error if there is an error parsing or executing the template.
-func (t *ServiceGenerator) generate() (string, error) {
+func (t *ServiceGenerator) generate(ctx context.Context) (string, error) {
 	// Render the stub agent template.
 	// The template is parsed and executed with the StubTemplate instance as the data.
 	// The generated template is returned as a string.
@@ -259,15 +293,21 @@ func (t *ServiceGenerator) generate() (string, error) {
 	}
 
 	// Check if the operating system is OpenWrt.
-	// If it is, write the content to the openwrt service file.
+	// If it is, write the UCI configuration of the agent and the content to the openwrt service file.
+	// The init script must be executable. It is only readable by root if it contains the auth token.
 	if featnix.IsOpenWrt() {
-		return t.writeToFile(openwrtServicePath, content)
+		if err := t.generateUCI(ctx); err != nil {
+			return "", err
+		}
+
+		return t.writeToFile(openwrtServicePath, content, t.mode(0o755, 0o700)) //nolint:mnd
 	}
 
 	// Check if the operating system supports systemd.
 	// If it does, write the content to the systemd service file.
+	// The unit file is only readable by root if it contains the auth token.
 	if featnix.HasSystemd() {
-		return t.writeToFile(systemdServicePath, content)
+		return t.writeToFile(systemdServicePath, content, t.mode(0o644, 0o600)) //nolint:mnd
 	}
 
 	// If the operating system is neither OpenWrt nor supports systemd,
@@ -275,16 +315,117 @@ func (t *ServiceGenerator) generate() (string, error) {
 	return "", errUnsupportedOS
 }
 
-// writeToFile writes the content to the specified file path, if the file does not already exist.
+// generateUCI writes the UCI configuration of the agent.
+//
+// An existing configuration is kept, so that the changes made with uci survive a repeated
+// registration, only the server, the port and the IDs of its "main" section are updated.
+// A warning is logged if the configuration has no such section.
+//
+// Returns:
+//
+//	error: An error if there is an error rendering, writing or updating the configuration.
+func (t *ServiceGenerator) generateUCI(ctx context.Context) error {
+	sections, err := uci.ParseFile(openwrtConfigPath)
+	if errors.Is(err, os.ErrNotExist) {
+		content, err := t.renderTemplate(uciTemplate)
+		if err != nil {
+			return err
+		}
+
+		_, err = t.writeToFile(openwrtConfigPath, content, 0o644) //nolint:mnd
+
+		return err
+	}
+
+	if err != nil {
+		return err
+	}
+
+	section, err := uci.Find(sections, uciSection)
+	if err != nil || section.Type != "agent" {
+		zerolog.Ctx(ctx).Warn().
+			Str("host", t.context.Host).
+			Int("port", t.context.Port).
+			Strs("id", t.context.IDs).
+			Msgf("%s has no agent section %q, the server and the IDs are not written, set them with uci",
+				openwrtConfigPath, uciSection)
+
+		return nil
+	}
+
+	cmd := exec.Command("uci", "batch")
+	cmd.Stdin = strings.NewReader(t.uciBatch(section))
+	stderr := new(bytes.Buffer)
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to update %s: %w: %s", openwrtConfigPath, err, stderr.String())
+	}
+
+	zerolog.Ctx(ctx).Info().
+		Str("host", t.context.Host).
+		Int("port", t.context.Port).
+		Strs("id", t.context.IDs).
+		Msgf("updated the server and the IDs of the agent section %q of %s", uciSection, openwrtConfigPath)
+
+	return nil
+}
+
+// uciBatch returns the uci batch commands that set the server, the port and the IDs of the section.
+//
+// Parameters:
+// - section: The current "main" section, its IDs are replaced.
+//
+// Returns:
+// - string: The commands for "uci batch".
+func (t *ServiceGenerator) uciBatch(section uci.Section) string {
+	var batch strings.Builder
+
+	option := "vakeel." + uciSection
+
+	fmt.Fprintf(&batch, "set %s.server=%s\n", option, shellQuote(t.context.Host))
+	fmt.Fprintf(&batch, "set %s.port=%d\n", option, t.context.Port)
+
+	if _, ok := section.Options["id"]; ok {
+		fmt.Fprintf(&batch, "delete %s.id\n", option)
+	}
+
+	for _, id := range t.context.IDs {
+		fmt.Fprintf(&batch, "add_list %s.id=%s\n", option, shellQuote(id))
+	}
+
+	batch.WriteString("commit vakeel\n")
+
+	return batch.String()
+}
+
+// mode returns the permissions of the service file.
+//
+// Parameters:
+// - public: The permissions if the service file does not contain secrets.
+// - private: The permissions if the service file contains the auth token.
+//
+// Returns:
+// - os.FileMode: The permissions of the service file.
+func (t *ServiceGenerator) mode(public, private os.FileMode) os.FileMode {
+	if t.context.Token != "" {
+		return private
+	}
+
+	return public
+}
+
+// writeToFile writes the content to the specified file path, replacing the file if it already exists.
 //
 // Parameters:
 // - filePath: The path to the file where the content will be written.
 // - content: The content to be written to the file.
+// - perm: The permissions of the file.
 //
 // Returns:
 // - string: The file path.
 // - error: An error if there is an error writing the file.
-func (t *ServiceGenerator) writeToFile(filePath string, content string) (string, error) {
+func (t *ServiceGenerator) writeToFile(filePath string, content string, perm os.FileMode) (string, error) {
 	// Create the directory for the file if it doesn't exist.
 	err := os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
 	if err != nil {
@@ -292,12 +433,17 @@ func (t *ServiceGenerator) writeToFile(filePath string, content string) (string,
 	}
 
 	// Open the file for writing.
-	f, err := os.Create(filePath)
+	f, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 	if err != nil {
 		return "", err
 	}
 	defer f.Close()
 
+	// Restrict the permissions before writing, the file may have existed with other permissions.
+	if err := f.Chmod(perm); err != nil {
+		return "", err
+	}
+
 	// Write the content to the file.
 	_, err = f.WriteString(content)
 	if err != nil {
@@ -327,12 +473,28 @@ func (t *ServiceGenerator) render() (string, error) {
 		return "", errStubNotFound
 	}
 
+	return t.renderTemplate(*stubTemplate)
+}
+
+// renderTemplate executes the given template with the StubTemplate instance as the data.
+//
+// Parameters:
+// - stubTemplate: The text of the template.
+//
+// Returns:
+//
+//	string: The generated file.
+//	error:  An error if there is an error parsing or executing the template.
+func (t *ServiceGenerator) renderTemplate(stubTemplate string) (string, error) {
 	// Parse the stub agent template.
 	//
 	// The template is parsed using the template package's Parse function. The
 	// template is named "stub" and the template string is stored in the
 	// stubTemplate variable.
-	tmpl, err := template.New("stub").Parse(*stubTemplate)
+	// The arguments of the command lines are quoted with the quote functions.
+	tmpl, err := template.New("stub").
+		Funcs(template.FuncMap{"shell": shellQuote, "systemd": systemdQuote}).
+		Parse(stubTemplate)
 	if err != nil {
 		// Return an error if there is an error parsing the template.
 		return "", err
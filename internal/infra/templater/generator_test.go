@@ -0,0 +1,115 @@
+package templater
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/bavix/vakeel/pkg/uci"
+)
+
+func TestRenderQuotesArguments(t *testing.T) {
+	t.Parallel()
+
+	generator := &ServiceGenerator{context: Data{
+		AppPath: "/opt/vakeel agent/vakeel",
+		IDs:     []string{"224f8a59-6705-4f3e-b7de-177757932aad=Main router"},
+		Host:    "way.example.com",
+		Port:    443,
+		Args:    []string{"--ca-file=/etc/vakeel/my ca.pem", "--state-dir=/var/lib/it's $HOME 100%"},
+	}}
+
+	tests := []struct {
+		name     string
+		template string
+		prefix   string
+		shell    bool
+		want     []string
+	}{
+		{
+			name:     "procd",
+			template: openwrtTemplate,
+			prefix:   "procd_set_param command ",
+			shell:    true,
+			want: []string{
+				"/opt/vakeel agent/vakeel", "agent", "--uci-section=@agent[$agent_index]", "--instance=$section",
+				"--ca-file=/etc/vakeel/my ca.pem", "--state-dir=/var/lib/it's $HOME 100%",
+			},
+		},
+		{
+			name:     "systemd",
+			template: systemdTemplate,
+			prefix:   "ExecStart=",
+			want: []string{
+				"/opt/vakeel agent/vakeel", "agent", "--id=224f8a59-6705-4f3e-b7de-177757932aad=Main router",
+				"--host=way.example.com", "--port=443",
+				"--ca-file=/etc/vakeel/my ca.pem", "--state-dir=/var/lib/it's $HOME 100%",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			content, err := generator.renderTemplate(test.template)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, line := range strings.Split(content, "\n") {
+				if command, ok := strings.CutPrefix(strings.TrimSpace(line), test.prefix); ok {
+					if got := splitCommand(command, test.shell); !slices.Equal(got, test.want) {
+						t.Fatalf("command %s = %q, want %q", command, got, test.want)
+					}
+
+					return
+				}
+			}
+
+			t.Fatalf("no command in\n%s", content)
+		})
+	}
+}
+
+func TestUCIBatch(t *testing.T) {
+	t.Parallel()
+
+	generator := &ServiceGenerator{context: Data{
+		IDs:  []string{"224f8a59-6705-4f3e-b7de-177757932aad", "324f8a59-6705-4f3e-b7de-177757932aad=Main router"},
+		Host: "new.example.com",
+		Port: 443,
+	}}
+
+	tests := []struct {
+		name    string
+		section uci.Section
+		want    string
+	}{
+		{
+			name:    "replace the IDs",
+			section: uci.Section{Type: "agent", Name: "main", Options: map[string][]string{"id": {"old"}}},
+			want: "set vakeel.main.server=new.example.com\n" +
+				"set vakeel.main.port=443\n" +
+				"delete vakeel.main.id\n" +
+				"add_list vakeel.main.id=224f8a59-6705-4f3e-b7de-177757932aad\n" +
+				"add_list vakeel.main.id='324f8a59-6705-4f3e-b7de-177757932aad=Main router'\n" +
+				"commit vakeel\n",
+		},
+		{
+			name:    "no IDs yet",
+			section: uci.Section{Type: "agent", Name: "main", Options: map[string][]string{}},
+			want: "set vakeel.main.server=new.example.com\n" +
+				"set vakeel.main.port=443\n" +
+				"add_list vakeel.main.id=224f8a59-6705-4f3e-b7de-177757932aad\n" +
+				"add_list vakeel.main.id='324f8a59-6705-4f3e-b7de-177757932aad=Main router'\n" +
+				"commit vakeel\n",
+		},
+	}
+
+	for _, test := range tests {
+		if got := generator.uciBatch(test.section); got != test.want {
+			t.Errorf("%s: uciBatch() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package templater
+
+import (
+	"regexp"
+	"strings"
+)
+
+// safeArg matches the arguments that need no quotes, neither in the shell nor in a systemd command line.
+var safeArg = regexp.MustCompile(`^[A-Za-z0-9_@%+=:,./-]+$`)
+
+// shellQuote quotes the argument for the shell, e.g. in the OpenWrt init script.
+//
+// The argument is put in single quotes unless it only contains safe characters,
+// a single quote within it is closed, escaped and reopened.
+func shellQuote(arg string) string {
+	if safeArg.MatchString(arg) {
+		return arg
+	}
+
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// systemdQuote quotes the argument for a command line of a systemd unit, e.g. ExecStart.
+//
+// The specifiers and the environment variables are escaped as "%%" and "$$",
+// the argument is put in double quotes unless it only contains safe characters.
+func systemdQuote(arg string) string {
+	arg = strings.NewReplacer("%", "%%", "$", "$$").Replace(arg)
+	if safeArg.MatchString(arg) {
+		return arg
+	}
+
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// splitCommand splits the command line of a service file into its arguments and removes the quotes.
+//
+// Single quotes keep the text as is, within double quotes and outside of quotes a backslash escapes
+// the next character. The systemd escapes "%%" and "$$" are replaced unless the command is a shell command.
+func splitCommand(line string, shell bool) []string {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, char := range line {
+		switch {
+		case escaped:
+			current.WriteRune(char)
+
+			escaped = false
+		case quote == '\'':
+			if char == '\'' {
+				quote = 0
+			} else {
+				current.WriteRune(char)
+			}
+		case char == '\\':
+			inArg, escaped = true, true
+		case quote == '"':
+			if char == '"' {
+				quote = 0
+			} else {
+				current.WriteRune(char)
+			}
+		case char == '\'' || char == '"':
+			inArg, quote = true, char
+		case char == ' ' || char == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+			}
+
+			inArg = false
+		default:
+			inArg = true
+
+			current.WriteRune(char)
+		}
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	if !shell {
+		unescape := strings.NewReplacer("%%", "%", "$$", "$")
+		for i, arg := range args {
+			args[i] = unescape.Replace(arg)
+		}
+	}
+
+	return args
+}
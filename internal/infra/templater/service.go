@@ -0,0 +1,132 @@
+package templater
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bavix/vakeel/pkg/featnix"
+)
+
+// ErrServiceNotInstalled is the error returned when the service file of the agent does not exist.
+var ErrServiceNotInstalled = errors.New("service is not installed")
+
+// ErrNoServiceManager is the error returned when the operating system has no supported service manager.
+var ErrNoServiceManager = errors.New("neither OpenWrt nor systemd is found")
+
+// errNoCommand is the error returned when the command of the agent is not found in the service file.
+var errNoCommand = errors.New("command of the agent not found in the service file")
+
+// Service is the service of the agent installed by Register.
+type Service struct {
+	// Path is the path to the service file.
+	Path string
+	// Command is the command line of the agent, the path to the binary followed by the arguments.
+	Command []string
+	// openwrt is set if the service is the OpenWrt init script, otherwise it is the systemd unit.
+	openwrt bool
+}
+
+// InstalledService reads the service of the agent installed for the service manager of the operating system.
+//
+// Returns:
+// - *Service: A pointer to the Service instance.
+// - error: ErrNoServiceManager, ErrServiceNotInstalled or an error if the service file cannot be read.
+func InstalledService() (*Service, error) {
+	switch {
+	case featnix.IsOpenWrt():
+		return readService(openwrtServicePath, "procd_set_param command ", true)
+	case featnix.HasSystemd():
+		return readService(systemdServicePath, "ExecStart=", false)
+	default:
+		return nil, ErrNoServiceManager
+	}
+}
+
+// AppPath returns the path to the binary run by the service.
+func (s *Service) AppPath() string {
+	return s.Command[0]
+}
+
+// Flag returns the values of the flag of the agent command, e.g. the IDs for "id".
+// Both the "--name=value" and the "--name value" forms are recognized.
+func (s *Service) Flag(name string) []string {
+	var values []string
+
+	prefix := "--" + name
+
+	for i := 1; i < len(s.Command); i++ {
+		switch arg := s.Command[i]; {
+		case strings.HasPrefix(arg, prefix+"="):
+			values = append(values, strings.TrimPrefix(arg, prefix+"="))
+		case arg == prefix && i+1 < len(s.Command):
+			i++
+			values = append(values, s.Command[i])
+		}
+	}
+
+	return values
+}
+
+// Enabled reports whether the service is started on boot.
+//
+// Returns:
+// - bool: Whether the service is enabled.
+// - error: An error if the service manager cannot be asked.
+func (s *Service) Enabled() (bool, error) {
+	cmd := exec.Command("systemctl", "is-enabled", "--quiet", "vakeel.service")
+	if s.openwrt {
+		cmd = exec.Command(openwrtServicePath, "enabled")
+	}
+
+	err := cmd.Run()
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// readService reads the command of the agent from the service file.
+//
+// Parameters:
+// - path: The path to the service file.
+// - prefix: The text that precedes the command on its line.
+// - openwrt: Whether the service file is the OpenWrt init script.
+//
+// Returns:
+// - *Service: A pointer to the Service instance.
+// - error: An error if the service file cannot be read or has no command.
+func readService(path, prefix string, openwrt bool) (*Service, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrServiceNotInstalled
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if command, ok := strings.CutPrefix(line, prefix); ok {
+			if args := splitCommand(command, openwrt); len(args) > 0 {
+				return &Service{Path: path, Command: args, openwrt: openwrt}, nil
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, errNoCommand
+}
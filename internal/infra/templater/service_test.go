@@ -0,0 +1,105 @@
+package templater
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestServiceFlag(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	tests := []struct {
+		name    string
+		content string
+		prefix  string
+		openwrt bool
+		flag    string
+		want    []string
+	}{
+		{
+			name: "systemd",
+			content: "[Service]\nType=simple\n" +
+				`ExecStart="/opt/my tools/vakeel" agent --id=224f8a59-6705-4f3e-b7de-177757932aad ` +
+				`"--id=324f8a59-6705-4f3e-b7de-177757932aad=Main router" --host=way.example.com --port=443` + "\n",
+			prefix: "ExecStart=",
+			flag:   "id",
+			want:   []string{"224f8a59-6705-4f3e-b7de-177757932aad", "324f8a59-6705-4f3e-b7de-177757932aad=Main router"},
+		},
+		{
+			name:    "systemd escapes",
+			content: `ExecStart=/usr/bin/vakeel agent "--state-dir=/var/lib/100%% $$HOME"` + "\n",
+			prefix:  "ExecStart=",
+			flag:    "state-dir",
+			want:    []string{"/var/lib/100% $HOME"},
+		},
+		{
+			name: "init script",
+			content: "start_agent() {\n" +
+				`        procd_set_param command /usr/bin/vakeel agent --uci-section="@agent[$agent_index]" ` +
+				`--instance="$section" '--config=/etc/vakeel/my config.yaml' --state-dir /tmp/vakeel` + "\n}\n",
+			prefix:  "procd_set_param command ",
+			openwrt: true,
+			flag:    "config",
+			want:    []string{"/etc/vakeel/my config.yaml"},
+		},
+		{
+			name:    "separate value",
+			content: "        procd_set_param command /usr/bin/vakeel agent --state-dir '/tmp/it'\\''s'\n",
+			prefix:  "procd_set_param command ",
+			openwrt: true,
+			flag:    "state-dir",
+			want:    []string{"/tmp/it's"},
+		},
+		{
+			name:    "missing flag",
+			content: "ExecStart=/usr/bin/vakeel agent --config=/etc/vakeel/config.yaml\n",
+			prefix:  "ExecStart=",
+			flag:    "id",
+			want:    nil,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(dir, test.name)
+			if err := os.WriteFile(path, []byte(test.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			service, err := readService(path, test.prefix, test.openwrt)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := service.Flag(test.flag); !slices.Equal(got, test.want) {
+				t.Fatalf("Flag(%q) = %q, want %q", test.flag, got, test.want)
+			}
+		})
+	}
+}
+
+func TestReadServiceErrors(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	if _, err := readService(filepath.Join(dir, "missing"), "ExecStart=", false); !errors.Is(err, ErrServiceNotInstalled) {
+		t.Fatalf("readService() error = %v, want %v", err, ErrServiceNotInstalled)
+	}
+
+	path := filepath.Join(dir, "vakeel.service")
+	if err := os.WriteFile(path, []byte("[Service]\nType=simple\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := readService(path, "ExecStart=", false); !errors.Is(err, errNoCommand) {
+		t.Fatalf("readService() error = %v, want %v", err, errNoCommand)
+	}
+}
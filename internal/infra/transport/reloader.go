@@ -0,0 +1,226 @@
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// errKeyPairRequired is the error returned when only one of the certificate and the key is configured.
+var errKeyPairRequired = errors.New("both the client certificate and the key are required")
+
+// CertReloader provides the client certificate of the agent and reloads it when
+// the certificate or the key file changes.
+//
+// The files are checked on every TLS handshake and periodically by Watch, so a
+// rotated key pair is used on the next reconnect without restarting the agent.
+// A broken key pair on disk is reported and the previous one stays in use.
+type CertReloader struct {
+	// certFile is the path to the PEM-encoded client certificate.
+	certFile string
+	// keyFile is the path to the PEM-encoded private key.
+	keyFile string
+	// expiryWarning is how long before the expiry of the certificate a warning is logged.
+	expiryWarning time.Duration
+	// logger is used to report reloads and expiry warnings.
+	logger zerolog.Logger
+
+	// mu guards the fields below.
+	mu sync.Mutex
+	// certificate is the current key pair.
+	certificate *tls.Certificate
+	// certStamp and keyStamp identify the versions of the files the key pair was loaded from.
+	certStamp, keyStamp fileStamp
+	// warned reports whether the expiry warning has been logged for the current key pair.
+	warned bool
+	// expired reports whether the expiry error has been logged for the current key pair.
+	expired bool
+}
+
+// fileStamp identifies a version of a file by its modification time and size.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewCertReloader creates a new CertReloader and loads the key pair.
+//
+// Parameters:
+// - certFile: The path to the PEM-encoded client certificate.
+// - keyFile: The path to the PEM-encoded private key.
+// - expiryWarning: How long before the expiry of the certificate a warning is logged.
+// - logger: The logger used to report reloads and expiry warnings.
+//
+// Returns:
+// - *CertReloader: A pointer to the CertReloader instance.
+// - error: An error if the key pair cannot be loaded.
+func NewCertReloader(
+	certFile string,
+	keyFile string,
+	expiryWarning time.Duration,
+	logger zerolog.Logger,
+) (*CertReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, errKeyPairRequired
+	}
+
+	reloader := &CertReloader{
+		certFile:      certFile,
+		keyFile:       keyFile,
+		expiryWarning: expiryWarning,
+		logger:        logger,
+	}
+
+	if _, err := reloader.reload(); err != nil {
+		return nil, err
+	}
+
+	return reloader, nil
+}
+
+// GetClientCertificate returns the current key pair.
+//
+// It satisfies the tls.Config.GetClientCertificate callback and picks up a
+// rotated key pair before returning it.
+func (r *CertReloader) GetClientCertificate(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+	if _, err := r.reload(); err != nil {
+		r.logger.Error().Err(err).Msg("failed to reload client certificate, using the previous one")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.certificate, nil
+}
+
+// Watch checks the key pair files at the given interval until the context is cancelled.
+//
+// It reloads the key pair when the files change and logs a warning when the
+// certificate is about to expire.
+//
+// Parameters:
+// - ctx: The context that stops the watcher.
+// - interval: The interval between checks.
+func (r *CertReloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.reload(); err != nil {
+				r.logger.Error().Err(err).Msg("failed to reload client certificate, using the previous one")
+			}
+
+			r.checkExpiry()
+		}
+	}
+}
+
+// reload loads the key pair if the files changed since the last load.
+//
+// Returns:
+// - bool: True if a new key pair has been loaded.
+// - error: An error if the files cannot be read or do not form a valid key pair.
+func (r *CertReloader) reload() (bool, error) {
+	certStamp, err := stat(r.certFile)
+	if err != nil {
+		return false, err
+	}
+
+	keyStamp, err := stat(r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Nothing to do if the files have not changed since the last load.
+	if r.certificate != nil && certStamp == r.certStamp && keyStamp == r.keyStamp {
+		return false, nil
+	}
+
+	certificate, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, fmt.Errorf("failed to load client certificate: %w", err)
+	}
+
+	r.certificate = &certificate
+	r.certStamp, r.keyStamp = certStamp, keyStamp
+	r.warned, r.expired = false, false
+
+	if leaf := certificate.Leaf; leaf != nil {
+		r.logger.Info().
+			Str("subject", leaf.Subject.String()).
+			Time("not_after", leaf.NotAfter).
+			Msg("client certificate loaded")
+	}
+
+	r.checkExpiryLocked()
+
+	return true, nil
+}
+
+// checkExpiry logs a warning if the current certificate is about to expire.
+func (r *CertReloader) checkExpiry() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.checkExpiryLocked()
+}
+
+// checkExpiryLocked logs a warning once per key pair if the certificate is about to expire
+// and an error once it has expired. The caller must hold the mutex.
+func (r *CertReloader) checkExpiryLocked() {
+	leaf := leafOf(r.certificate)
+	if leaf == nil {
+		return
+	}
+
+	left := time.Until(leaf.NotAfter)
+
+	switch {
+	case left <= 0:
+		if !r.expired {
+			r.expired = true
+			r.logger.Error().Time("not_after", leaf.NotAfter).Msg("client certificate has expired")
+		}
+	case left <= r.expiryWarning:
+		if !r.warned {
+			r.warned = true
+			r.logger.Warn().
+				Time("not_after", leaf.NotAfter).
+				Dur("left", left).
+				Msg("client certificate expires soon")
+		}
+	}
+}
+
+// leafOf returns the parsed leaf certificate of the key pair.
+func leafOf(certificate *tls.Certificate) *x509.Certificate {
+	if certificate == nil {
+		return nil
+	}
+
+	return certificate.Leaf
+}
+
+// stat returns the stamp of the current version of the file.
+func stat(path string) (fileStamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}, err
+	}
+
+	return fileStamp{modTime: info.ModTime(), size: info.Size()}, nil
+}
@@ -0,0 +1,109 @@
+package transport
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// writeKeyPair writes a new self-signed key pair with the given common name to the files
+// and moves their modification time forward, so that the change is seen within the same second.
+func writeKeyPair(t *testing.T, certFile, keyFile, name string, modTime time.Time) {
+	t.Helper()
+
+	certificate, key := newCertificate(t, name, nil, nil)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeFile(t, certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certificate.Raw}), modTime)
+	writeFile(t, keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), modTime)
+}
+
+// writeFile writes the content to the file and sets its modification time.
+func writeFile(t *testing.T, path string, content []byte, modTime time.Time) {
+	t.Helper()
+
+	if err := os.WriteFile(path, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// clientCommonName returns the common name of the client certificate of the next handshake.
+func clientCommonName(t *testing.T, reloader *CertReloader) string {
+	t.Helper()
+
+	certificate, err := reloader.GetClientCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate.Leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+	start := time.Now().Add(-time.Hour)
+
+	writeKeyPair(t, certFile, keyFile, "first", start)
+
+	reloader, err := NewCertReloader(certFile, keyFile, time.Minute, zerolog.Nop())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := clientCommonName(t, reloader); got != "first" {
+		t.Fatalf("common name = %q, want first", got)
+	}
+
+	// The rotated key pair is used on the next handshake.
+	writeKeyPair(t, certFile, keyFile, "second", start.Add(time.Minute))
+
+	if got := clientCommonName(t, reloader); got != "second" {
+		t.Fatalf("common name = %q, want the rotated second", got)
+	}
+
+	// A broken key pair is reported and the previous one stays in use.
+	writeFile(t, certFile, []byte("not a certificate"), start.Add(2*time.Minute))
+
+	if got := clientCommonName(t, reloader); got != "second" {
+		t.Fatalf("common name = %q, want the previous second", got)
+	}
+
+	// A key that does not match the certificate is broken as well.
+	writeKeyPair(t, filepath.Join(dir, "other.pem"), keyFile, "other", start.Add(3*time.Minute))
+
+	if got := clientCommonName(t, reloader); got != "second" {
+		t.Fatalf("common name = %q, want the previous second", got)
+	}
+}
+
+func TestNewCertReloaderInvalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "client.pem"), filepath.Join(dir, "client.key")
+
+	if _, err := NewCertReloader(certFile, "", time.Minute, zerolog.Nop()); !errors.Is(err, errKeyPairRequired) {
+		t.Fatalf("NewCertReloader() error = %v, want %v", err, errKeyPairRequired)
+	}
+
+	if _, err := NewCertReloader(certFile, keyFile, time.Minute, zerolog.Nop()); err == nil {
+		t.Fatal("NewCertReloader() without files succeeded")
+	}
+}
@@ -0,0 +1,204 @@
+package transport
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// pinPrefix is the optional prefix of SPKI pins, i.e. "sha256/<base64>".
+const pinPrefix = "sha256/"
+
+// errNoCertificates is the error returned when the CA bundle does not contain any certificate.
+var errNoCertificates = errors.New("no certificates found")
+
+// errInvalidPin is the error returned when an SPKI pin is not a base64-encoded SHA-256 digest.
+var errInvalidPin = errors.New("pin must be a base64-encoded SHA-256 digest")
+
+// errPinMismatch is the error returned when none of the server certificates matches the pins.
+var errPinMismatch = errors.New("server certificate does not match any of the pinned keys")
+
+// errPlaintextOptions is the error returned when TLS options are combined with plaintext.
+var errPlaintextOptions = errors.New("TLS options cannot be used with plaintext")
+
+// Options holds the transport security settings of the connection to the server.
+type Options struct {
+	// Plaintext disables TLS. It must be requested explicitly.
+	Plaintext bool
+	// CAFile is the path to a PEM bundle of the CAs trusted to sign the server certificate.
+	// The system roots are used if it is empty.
+	CAFile string
+	// ServerName overrides the name used for SNI and to verify the server certificate.
+	ServerName string
+	// Pins are the base64-encoded SHA-256 digests of the SubjectPublicKeyInfo of the
+	// certificates the server is allowed to present, optionally prefixed with "sha256/".
+	// Any certificate of the verified chain may match.
+	Pins []string
+	// Certificates provides the client certificate for mutual TLS.
+	// No client certificate is sent if it is nil.
+	Certificates *CertReloader
+}
+
+// Credentials creates the gRPC transport credentials from the options.
+//
+// Parameters:
+// - opts: The transport security settings.
+//
+// Returns:
+// - credentials.TransportCredentials: The transport credentials of the connection.
+// - error: An error if the CA bundle cannot be loaded or the pins are invalid.
+func Credentials(opts Options) (credentials.TransportCredentials, error) {
+	if opts.Plaintext {
+		// Refuse to silently ignore TLS options when plaintext is requested.
+		if opts.CAFile != "" || opts.ServerName != "" || len(opts.Pins) > 0 || opts.Certificates != nil {
+			return nil, errPlaintextOptions
+		}
+
+		return insecure.NewCredentials(), nil
+	}
+
+	config, err := Config(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return credentials.NewTLS(config), nil
+}
+
+// Config creates the TLS configuration from the options.
+//
+// Parameters:
+// - opts: The transport security settings.
+//
+// Returns:
+// - *tls.Config: The TLS configuration of the connection.
+// - error: An error if the CA bundle cannot be loaded or the pins are invalid.
+func Config(opts Options) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: opts.ServerName,
+	}
+
+	// Load the custom CA bundle, otherwise the system roots are used.
+	if opts.CAFile != "" {
+		pool, err := loadCertPool(opts.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = pool
+	}
+
+	// Present the client certificate if the server asks for it.
+	if opts.Certificates != nil {
+		config.GetClientCertificate = opts.Certificates.GetClientCertificate
+	}
+
+	// Verify the pins after the chain has been verified.
+	if len(opts.Pins) > 0 {
+		pins, err := parsePins(opts.Pins)
+		if err != nil {
+			return nil, err
+		}
+
+		config.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyPins(state, pins)
+		}
+	}
+
+	return config, nil
+}
+
+// loadCertPool loads the PEM-encoded certificates from the given file.
+//
+// Parameters:
+// - path: The path to the PEM bundle.
+//
+// Returns:
+// - *x509.CertPool: The pool with the certificates from the bundle.
+// - error: An error if the file cannot be read or does not contain any certificate.
+func loadCertPool(path string) (*x509.CertPool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf("failed to load CA bundle %s: %w", path, errNoCertificates)
+	}
+
+	return pool, nil
+}
+
+// parsePins decodes the SPKI pins.
+//
+// Parameters:
+// - values: The pins in the "[sha256/]<base64>" format.
+//
+// Returns:
+// - [][]byte: The decoded SHA-256 digests.
+// - error: An error if any of the pins is invalid.
+func parsePins(values []string) ([][]byte, error) {
+	pins := make([][]byte, 0, len(values))
+
+	for _, value := range values {
+		digest, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(strings.TrimSpace(value), pinPrefix))
+		if err != nil || len(digest) != sha256.Size {
+			return nil, fmt.Errorf("invalid pin %q: %w", value, errInvalidPin)
+		}
+
+		pins = append(pins, digest)
+	}
+
+	return pins, nil
+}
+
+// verifyPins checks that one of the certificates presented by the server matches one of the pins.
+//
+// Only the verified chains are checked, so that a pinned CA matches as well as
+// a pinned leaf certificate, while extra certificates sent by the server are ignored.
+//
+// Parameters:
+// - state: The state of the TLS connection.
+// - pins: The decoded SHA-256 digests.
+//
+// Returns:
+// - error: An error if none of the certificates matches the pins.
+func verifyPins(state tls.ConnectionState, pins [][]byte) error {
+	for _, chain := range state.VerifiedChains {
+		for _, certificate := range chain {
+			digest := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+
+			for _, pin := range pins {
+				if subtle.ConstantTimeCompare(digest[:], pin) == 1 {
+					return nil
+				}
+			}
+		}
+	}
+
+	return errPinMismatch
+}
+
+// Pin returns the SPKI pin of the given certificate in the "sha256/<base64>" format.
+//
+// Parameters:
+// - certificate: The certificate to pin.
+//
+// Returns:
+// - string: The pin of the certificate.
+func Pin(certificate *x509.Certificate) string {
+	digest := sha256.Sum256(certificate.RawSubjectPublicKeyInfo)
+
+	return pinPrefix + base64.StdEncoding.EncodeToString(digest[:])
+}
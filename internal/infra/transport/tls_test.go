@@ -0,0 +1,94 @@
+package transport
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+// newCertificate creates a certificate signed by the parent, or a self-signed one if the parent is nil.
+func newCertificate(t *testing.T, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (
+	*x509.Certificate,
+	*ecdsa.PrivateKey,
+) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certificate, key
+}
+
+func TestParsePinsInvalid(t *testing.T) {
+	t.Parallel()
+
+	for _, pin := range []string{"sha256/not base64!", "sha256/AAAA", "sha1/" + Pin(&x509.Certificate{})[7:]} {
+		if _, err := parsePins([]string{pin}); !errors.Is(err, errInvalidPin) {
+			t.Errorf("parsePins(%q) error = %v, want %v", pin, err, errInvalidPin)
+		}
+	}
+}
+
+func TestVerifyPins(t *testing.T) {
+	t.Parallel()
+
+	ca, caKey := newCertificate(t, "ca", nil, nil)
+	leaf, _ := newCertificate(t, "vakeel.example.com", ca, caKey)
+	other, _ := newCertificate(t, "other", nil, nil)
+
+	// Pins are accepted with and without the prefix.
+	pins, err := parsePins([]string{Pin(leaf), Pin(ca)[len("sha256/"):], Pin(other)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	state := tls.ConnectionState{
+		PeerCertificates: []*x509.Certificate{leaf, ca, other},
+		VerifiedChains:   [][]*x509.Certificate{{leaf, ca}},
+	}
+
+	// Any certificate of the verified chain matches, one the server only sent along does not.
+	if err := verifyPins(state, pins[:1]); err != nil {
+		t.Fatalf("verifyPins() with the leaf pin = %v", err)
+	}
+
+	if err := verifyPins(state, pins[1:2]); err != nil {
+		t.Fatalf("verifyPins() with the CA pin = %v", err)
+	}
+
+	if err := verifyPins(state, pins[2:]); !errors.Is(err, errPinMismatch) {
+		t.Fatalf("verifyPins() with an unverified pin = %v, want %v", err, errPinMismatch)
+	}
+}
@@ -0,0 +1,163 @@
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// TokenEnv is the environment variable the auth token is read from.
+const TokenEnv = "VAKEEL_TOKEN"
+
+// credentialsDirectoryEnv is the environment variable systemd sets to the directory
+// with the credentials of the service, see systemd.exec(5).
+const credentialsDirectoryEnv = "CREDENTIALS_DIRECTORY"
+
+// TokenCredentialName is the name of the systemd credential that holds the auth token.
+const TokenCredentialName = "token"
+
+// errTokenSources is the error returned when the auth token is configured more than once.
+var errTokenSources = errors.New("the token and the token file cannot be used together")
+
+// errInvalidToken is the error returned when the auth token contains unsupported characters.
+var errInvalidToken = errors.New("token may only contain letters, digits and the characters - . _ ~ + / =")
+
+// errTokenPermissions is the error returned when the token file is accessible by other users.
+var errTokenPermissions = errors.New("token file must not be accessible by group or others")
+
+// tokenPattern matches the tokens that are accepted, i.e. the b64token syntax of RFC 6750.
+//
+// Tokens end up in HTTP headers and generated service files, so whitespace,
+// quoting and escape characters are not allowed.
+var tokenPattern = regexp.MustCompile(`^[A-Za-z0-9._~+/=-]+$`)
+
+// LoadToken loads the auth token of the agent.
+//
+// The token is taken from the first source that provides it:
+//   - the token value, e.g. from the --token flag;
+//   - the token file, which must not be accessible by group or others;
+//   - the VAKEEL_TOKEN environment variable;
+//   - the "token" systemd credential.
+//
+// An empty token means that the agent does not authenticate.
+//
+// Parameters:
+// - value: The token value.
+// - file: The path to the file with the token.
+//
+// Returns:
+// - string: The auth token.
+// - error: An error if the token cannot be loaded or is invalid.
+func LoadToken(value, file string) (string, error) {
+	if value != "" && file != "" {
+		return "", errTokenSources
+	}
+
+	token := value
+
+	switch {
+	case token != "":
+	case file != "":
+		content, err := readTokenFile(file)
+		if err != nil {
+			return "", err
+		}
+
+		token = content
+	case os.Getenv(TokenEnv) != "":
+		token = os.Getenv(TokenEnv)
+	case os.Getenv(credentialsDirectoryEnv) != "":
+		// systemd makes the credentials readable by the service user only,
+		// the file is absent if the unit does not set the credential.
+		content, err := os.ReadFile(filepath.Join(os.Getenv(credentialsDirectoryEnv), TokenCredentialName))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+
+		token = string(content)
+	}
+
+	token = strings.TrimSpace(token)
+	if err := ValidateToken(token); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// ValidateToken checks that the auth token only contains supported characters.
+// An empty token is valid and means that the agent does not authenticate.
+//
+// Parameters:
+// - token: The auth token.
+//
+// Returns:
+// - error: An error if the token is invalid.
+func ValidateToken(token string) error {
+	if token != "" && !tokenPattern.MatchString(token) {
+		return errInvalidToken
+	}
+
+	return nil
+}
+
+// readTokenFile reads the token from the file after checking its permissions.
+//
+// Parameters:
+// - path: The path to the file with the token.
+//
+// Returns:
+// - string: The content of the file.
+// - error: An error if the file cannot be read or is accessible by other users.
+func readTokenFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	if info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("%w: %s has mode %s", errTokenPermissions, path, info.Mode().Perm())
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token file: %w", err)
+	}
+
+	return string(content), nil
+}
+
+// TokenCredentials attaches the auth token to every RPC as a bearer token.
+//
+// It implements the credentials.PerRPCCredentials interface.
+type TokenCredentials struct {
+	// token is the auth token.
+	token string
+}
+
+// NewTokenCredentials creates a new TokenCredentials instance.
+//
+// Parameters:
+// - token: The auth token.
+//
+// Returns:
+// - *TokenCredentials: A pointer to the TokenCredentials instance.
+func NewTokenCredentials(token string) *TokenCredentials {
+	return &TokenCredentials{token: token}
+}
+
+// GetRequestMetadata returns the authorization metadata of the RPC.
+func (c *TokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{
+		"authorization": "Bearer " + c.token,
+	}, nil
+}
+
+// RequireTransportSecurity reports that the token must only be sent over TLS.
+func (c *TokenCredentials) RequireTransportSecurity() bool {
+	return true
+}
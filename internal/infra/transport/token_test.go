@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadToken(t *testing.T) {
+	dir := t.TempDir()
+
+	// writeToken writes a token file with the given permissions.
+	writeToken := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+
+		return path
+	}
+
+	private := writeToken("private", "  from-file\n", 0o600)
+	groupReadable := writeToken("group", "from-file", 0o640)
+	worldReadable := writeToken("world", "from-file", 0o604)
+	writeToken(TokenCredentialName, "from-credential\n", 0o600)
+
+	tests := []struct {
+		name        string
+		value       string
+		file        string
+		env         string
+		credentials string
+		want        string
+		wantErr     error
+	}{
+		{name: "none", want: ""},
+		{name: "flag and file", value: "from-flag", file: private, wantErr: errTokenSources},
+		{name: "flag over environment", value: "from-flag", env: "from-env", want: "from-flag"},
+		{name: "file over environment", file: private, env: "from-env", want: "from-file"},
+		{name: "environment over credential", env: " from-env\n", credentials: dir, want: "from-env"},
+		{name: "credential", credentials: dir, want: "from-credential"},
+		{name: "missing credential", credentials: t.TempDir(), want: ""},
+		{name: "file readable by group", file: groupReadable, wantErr: errTokenPermissions},
+		{name: "file readable by others", file: worldReadable, wantErr: errTokenPermissions},
+		{name: "invalid characters", value: "from flag", wantErr: errInvalidToken},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv(TokenEnv, test.env)
+			t.Setenv(credentialsDirectoryEnv, test.credentials)
+
+			got, err := LoadToken(test.value, test.file)
+			if !errors.Is(err, test.wantErr) || got != test.want {
+				t.Fatalf("LoadToken() = %q, %v, want %q, %v", got, err, test.want, test.wantErr)
+			}
+		})
+	}
+}
@@ -2,18 +2,28 @@ package main
 
 import (
 	"context"
+	"os"
+	"syscall"
 
 	"github.com/bavix/vakeel/cmd"
+	"github.com/bavix/vakeel/pkg/sigctx"
 )
 
+// forceExitCode is the exit code used when the application is interrupted
+// a second time while it is shutting down.
+const forceExitCode = 130
+
 // main is the entry point of the application.
-// It executes the command with an empty context.
+// It executes the command with a context that is cancelled on SIGINT or SIGTERM.
 // The context is used to pass the configuration to the command.
 // The configuration is used to set the host and port of the server.
 func main() {
-	// Create an empty context.
-	// The context is used to pass configuration settings to the command.
-	ctx := context.Background()
+	// Create a context that is cancelled when the process is asked to stop.
+	// The first signal starts a graceful shutdown, the second one exits immediately.
+	ctx, stop := sigctx.WithShutdown(context.Background(), func(os.Signal) {
+		os.Exit(forceExitCode)
+	}, os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Execute the command with the context.
 	// The command is responsible for setting up and running the server.
@@ -0,0 +1,50 @@
+// Package atomicfile writes files that are never observed half-written.
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// dirMode is the file mode of the directories created for the file.
+const dirMode = 0o755
+
+// WriteFile writes the content to a temporary file and renames it to the given path,
+// so that the file is never observed half-written. The missing directories are created.
+//
+// Parameters:
+// - path: The path to the file.
+// - content: The content to write.
+// - perm: The file mode of the file.
+//
+// Returns:
+// - error: An error if the file cannot be written.
+func WriteFile(path string, content []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), dirMode); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
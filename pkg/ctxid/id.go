@@ -2,39 +2,112 @@ package ctxid
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 )
 
-// idKey is the key type used to store the ID value in the context.
+// ErrNilID is the error returned when the ID is the nil UUID.
+//
+// The nil UUID is the zero value of an unconfigured ID, so it is never accepted as an ID.
+var ErrNilID = errors.New("ID must not be the nil UUID " + uuid.Nil.String())
+
+// idKey is the key type used to store the ID values in the context.
 type idKey struct{}
 
+// Parse parses the ID value.
+//
+// id: The ID value to parse.
+// Returns: The parsed ID, or an error if the value is not a valid UUID or is the nil UUID.
+func Parse(id string) (uuid.UUID, error) {
+	vid, err := uuid.Parse(id)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("invalid ID %q: %w", id, err)
+	}
+
+	if vid == uuid.Nil {
+		return uuid.Nil, ErrNilID
+	}
+
+	return vid, nil
+}
+
 // WithID returns a new context with the provided ID value.
 //
+// An invalid ID or the nil UUID is not attached, the parent context is returned as is
+// and ID returns the nil UUID for it.
+//
+// Deprecated: Use WithParsedID, which returns the error of an invalid ID.
+//
 // ctx: The parent context.
 // id: The ID value to attach to the context.
-// Returns: A new context with the ID value attached.
+// Returns: A new context with the ID value attached, or the parent context if the ID is invalid.
 func WithID(ctx context.Context, id string) context.Context {
-	return context.WithValue(ctx, &idKey{}, uuid.MustParse(id))
+	vctx, err := WithParsedID(ctx, id)
+	if err != nil {
+		return ctx
+	}
+
+	return vctx
 }
 
-// ID retrieves the ID value from the provided context.
+// WithParsedID returns a new context with the provided ID value.
+//
+// ctx: The parent context.
+// id: The ID value to attach to the context.
+// Returns: A new context with the ID value attached, or an error if the ID is invalid or is the nil UUID.
+func WithParsedID(ctx context.Context, id string) (context.Context, error) {
+	vid, err := Parse(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return WithIDs(ctx, vid), nil
+}
+
+// WithIDs returns a new context with the provided ID values.
+//
+// ctx: The parent context.
+// ids: The ID values to attach to the context.
+// Returns: A new context with the ID values attached.
+func WithIDs(ctx context.Context, ids ...uuid.UUID) context.Context {
+	return context.WithValue(ctx, &idKey{}, ids)
+}
+
+// ID retrieves the first ID value from the provided context.
 //
 // ctx: The context to retrieve the ID from.
-// Returns: The ID value from the context, or an empty UUID if the context doesn't have an ID.
+// Returns: The first ID value from the context, or an empty UUID if the context doesn't have an ID.
 func ID(ctx context.Context) uuid.UUID {
+	// Get the ID values from the context.
+	// If there are no ID values, return an empty UUID.
+	// This is the default value for the ID if it is not set in the context.
+	ids := IDs(ctx)
+	if len(ids) == 0 {
+		return uuid.Nil
+	}
+
+	// Return the first ID value.
+	return ids[0]
+}
+
+// IDs retrieves the ID values from the provided context.
+//
+// ctx: The context to retrieve the IDs from.
+// Returns: The ID values from the context, or nil if the context doesn't have IDs.
+func IDs(ctx context.Context) []uuid.UUID {
 	// Get the value associated with the idKey from the context.
-	// The value is either a uuid.UUID or nil.
+	// The value is either a []uuid.UUID or nil.
 	value := ctx.Value(&idKey{})
 
-	// If the value is not a uuid.UUID, return an empty UUID.
-	// This is the default value for the ID if it is not set in the context.
-	vid, ok := value.(uuid.UUID)
+	// If the value is not a []uuid.UUID, return nil.
+	vids, ok := value.([]uuid.UUID)
 	if !ok {
-		return uuid.Nil
+		return nil
 	}
 
-	// Return the value as a uuid.UUID.
+	// Return the value as a []uuid.UUID.
 	// This is the value that is associated with the idKey in the context.
-	return vid
+	return vids
 }
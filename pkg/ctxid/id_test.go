@@ -0,0 +1,59 @@
+package ctxid
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestWithParsedID(t *testing.T) {
+	t.Parallel()
+
+	ctx, err := WithParsedID(context.Background(), "224f8a59-6705-4f3e-b7de-177757932aad")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := ID(ctx), uuid.MustParse("224f8a59-6705-4f3e-b7de-177757932aad"); got != want {
+		t.Fatalf("ID() = %s, want %s", got, want)
+	}
+
+	if _, err := WithParsedID(context.Background(), uuid.Nil.String()); !errors.Is(err, ErrNilID) {
+		t.Fatalf("WithParsedID() error = %v, want %v", err, ErrNilID)
+	}
+
+	for _, id := range []string{"", "router", "224f8a59-6705-4f3e-b7de"} {
+		if ctx, err := WithParsedID(context.Background(), id); err == nil || ctx != nil {
+			t.Errorf("WithParsedID(%q) = %v, %v, want an error", id, ctx, err)
+		}
+	}
+}
+
+func TestIDWithoutIDs(t *testing.T) {
+	t.Parallel()
+
+	if got := ID(context.Background()); got != uuid.Nil {
+		t.Fatalf("ID() = %s, want %s", got, uuid.Nil)
+	}
+}
+
+func TestWithID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		id   string
+		want uuid.UUID
+	}{
+		{id: "224f8a59-6705-4f3e-b7de-177757932aad", want: uuid.MustParse("224f8a59-6705-4f3e-b7de-177757932aad")},
+		{id: uuid.Nil.String(), want: uuid.Nil},
+		{id: "router", want: uuid.Nil},
+	}
+
+	for _, tt := range tests {
+		if got := ID(WithID(context.Background(), tt.id)); got != tt.want {
+			t.Errorf("ID(WithID(%q)) = %s, want %s", tt.id, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package sigctx
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+)
+
+// WithShutdown returns a copy of the parent context that is cancelled when one of
+// the given signals is received.
+//
+// The first signal cancels the returned context so that the application can stop
+// gracefully. Any further signal calls the force function, which is expected to
+// terminate the process immediately.
+//
+// ctx: The parent context.
+// force: The function called when a signal is received after the context has been cancelled.
+// signals: The signals that trigger the shutdown.
+// Returns: The context that is cancelled on the first signal and a function that
+// stops the signal handling and cancels the context.
+func WithShutdown(
+	ctx context.Context,
+	force func(os.Signal),
+	signals ...os.Signal,
+) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	// Buffer the channel so that a signal is not lost while the previous one is being handled.
+	ch := make(chan os.Signal, 2) //nolint:mnd
+	signal.Notify(ch, signals...)
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case <-ch:
+			// The first signal requests a graceful shutdown.
+			cancel()
+		}
+
+		select {
+		case <-done:
+			return
+		case sig := <-ch:
+			// The second signal requests an immediate exit.
+			force(sig)
+		}
+	}()
+
+	var once sync.Once
+
+	return ctx, func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+			cancel()
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package uci
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// errSyntax is the error returned when a line of the configuration cannot be parsed.
+var errSyntax = errors.New("syntax error")
+
+// errSectionNotFound is the error returned when the requested section does not exist.
+var errSectionNotFound = errors.New("section not found")
+
+// Section is a section of a UCI configuration, e.g. "config agent 'main'".
+type Section struct {
+	// Type is the type of the section, e.g. "agent".
+	Type string
+	// Name is the name of the section, empty for anonymous sections.
+	Name string
+	// Options are the options and lists of the section by name.
+	// Options have a single value, lists have one value per "list" line.
+	Options map[string][]string
+}
+
+// ParseFile parses the UCI configuration file at the given path.
+//
+// Parameters:
+//   - path: The path to the configuration file, e.g. /etc/config/vakeel.
+//
+// Returns:
+//   - The sections of the configuration in the order they appear.
+//   - An error if the file cannot be read or parsed.
+func ParseFile(path string) ([]Section, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	sections, err := Parse(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return sections, nil
+}
+
+// Parse parses a UCI configuration.
+//
+// The "package" statement and comments are ignored. A repeated "option" replaces
+// the previous value, while every "list" line appends a value.
+//
+// Parameters:
+//   - r: The reader of the configuration.
+//
+// Returns:
+//   - The sections of the configuration in the order they appear.
+//   - An error if the configuration cannot be parsed.
+func Parse(r io.Reader) ([]Section, error) {
+	var sections []Section
+
+	scanner := bufio.NewScanner(r)
+
+	for number := 1; scanner.Scan(); number++ {
+		words, err := split(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", number, err)
+		}
+
+		// Skip blank lines and comments.
+		if len(words) == 0 {
+			continue
+		}
+
+		switch {
+		case words[0] == "package":
+			continue
+		case words[0] == "config" && (len(words) == 2 || len(words) == 3): //nolint:mnd
+			section := Section{Type: words[1], Options: make(map[string][]string)}
+			if len(words) == 3 { //nolint:mnd
+				section.Name = words[2]
+			}
+
+			sections = append(sections, section)
+		case (words[0] == "option" || words[0] == "list") && len(words) == 3 && len(sections) > 0: //nolint:mnd
+			section := &sections[len(sections)-1]
+			name, value := words[1], words[2]
+
+			if words[0] == "option" {
+				section.Options[name] = []string{value}
+			} else {
+				section.Options[name] = append(section.Options[name], value)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: %w: %q", number, errSyntax, scanner.Text())
+		}
+	}
+
+	return sections, scanner.Err()
+}
+
+// Find returns the section with the given reference.
+//
+// The reference is either the name of a section or "@<type>[<index>]", the index
+// of the section among the sections of the given type, as used by the uci command.
+// Negative indexes count from the end.
+//
+// Parameters:
+//   - sections: The sections of the configuration.
+//   - ref: The reference of the section.
+//
+// Returns:
+//   - The section with the given reference.
+//   - An error if there is no such section.
+func Find(sections []Section, ref string) (Section, error) {
+	if sectionType, rawIndex, ok := parseIndexRef(ref); ok {
+		var typed []Section
+
+		for _, section := range sections {
+			if section.Type == sectionType {
+				typed = append(typed, section)
+			}
+		}
+
+		index, err := strconv.Atoi(rawIndex)
+		if err == nil && index < 0 {
+			index += len(typed)
+		}
+
+		if err != nil || index < 0 || index >= len(typed) {
+			return Section{}, fmt.Errorf("%w: %s", errSectionNotFound, ref)
+		}
+
+		return typed[index], nil
+	}
+
+	for _, section := range sections {
+		if section.Name == ref {
+			return section, nil
+		}
+	}
+
+	return Section{}, fmt.Errorf("%w: %s", errSectionNotFound, ref)
+}
+
+// parseIndexRef splits a reference in the "@<type>[<index>]" format.
+func parseIndexRef(ref string) (string, string, bool) {
+	if !strings.HasPrefix(ref, "@") || !strings.HasSuffix(ref, "]") {
+		return "", "", false
+	}
+
+	sectionType, index, ok := strings.Cut(strings.TrimSuffix(ref[1:], "]"), "[")
+
+	return sectionType, index, ok
+}
+
+// split splits a line into words, honouring single and double quotes and comments.
+func split(line string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+
+	for _, char := range line {
+		switch {
+		case escaped:
+			word.WriteRune(char)
+
+			escaped = false
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote == '\'':
+			word.WriteRune(char)
+		case char == '\\':
+			inWord, escaped = true, true
+		case quote != 0:
+			word.WriteRune(char)
+		case char == '\'' || char == '"':
+			inWord, quote = true, char
+		case char == '#':
+			if inWord {
+				words = append(words, word.String())
+			}
+
+			return words, nil
+		case unicode.IsSpace(char):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+
+				inWord = false
+			}
+		default:
+			inWord = true
+
+			word.WriteRune(char)
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("%w: unterminated quote", errSyntax)
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
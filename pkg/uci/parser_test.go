@@ -0,0 +1,113 @@
+package uci
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		line string
+		want []string
+	}{
+		{line: "# option id 'x'", want: nil},
+		{line: "\toption\tport 4643 # the default", want: []string{"option", "port", "4643"}},
+		{line: "option hook_connect 'echo #1'", want: []string{"option", "hook_connect", "echo #1"}},
+		{line: `option label "say \"hi\""`, want: []string{"option", "label", `say "hi"`}},
+		{line: `option label 'a\b'"c"d`, want: []string{"option", "label", `a\bcd`}},
+		{line: "option token ''", want: []string{"option", "token", ""}},
+	}
+
+	for _, tt := range tests {
+		got, err := split(tt.line)
+		if err != nil {
+			t.Fatalf("split(%q) error = %v", tt.line, err)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("split(%q) = %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	config := `package vakeel
+
+config agent 'main'
+	option server 'vakeel.example.com'
+	list id '224f8a59-6705-4f3e-b7de-177757932aad=router'
+	list id '324f8a59-6705-4f3e-b7de-177757932aad'
+
+config agent
+	option interval '30'
+	option interval '60'
+`
+
+	want := []Section{
+		{Type: "agent", Name: "main", Options: map[string][]string{
+			"server": {"vakeel.example.com"},
+			"id":     {"224f8a59-6705-4f3e-b7de-177757932aad=router", "324f8a59-6705-4f3e-b7de-177757932aad"},
+		}},
+		{Type: "agent", Options: map[string][]string{
+			"interval": {"60"},
+		}},
+	}
+
+	got, err := Parse(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Parse() = %+v, want %+v", got, want)
+	}
+
+	for _, config := range []string{"option port 4643\n", "config agent main\noption port\n", "config agent 'main\n"} {
+		if _, err := Parse(strings.NewReader(config)); !errors.Is(err, errSyntax) {
+			t.Errorf("Parse(%q) error = %v, want %v", config, err, errSyntax)
+		}
+	}
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+
+	sections := []Section{
+		{Type: "agent", Name: "main"},
+		{Type: "globals", Name: "globals"},
+		{Type: "agent"},
+	}
+
+	tests := []struct {
+		ref  string
+		want int
+	}{
+		{ref: "main", want: 0},
+		{ref: "@agent[1]", want: 2},
+		{ref: "@agent[-1]", want: 2},
+		{ref: "@globals[0]", want: 1},
+	}
+
+	for _, tt := range tests {
+		got, err := Find(sections, tt.ref)
+		if err != nil {
+			t.Fatalf("Find(%q) error = %v", tt.ref, err)
+		}
+
+		if !reflect.DeepEqual(got, sections[tt.want]) {
+			t.Errorf("Find(%q) = %+v, want %+v", tt.ref, got, sections[tt.want])
+		}
+	}
+
+	for _, ref := range []string{"@agent[2]", "@agent[-3]", "@agent", "missing"} {
+		if _, err := Find(sections, ref); !errors.Is(err, errSectionNotFound) {
+			t.Errorf("Find(%q) error = %v, want %v", ref, err, errSectionNotFound)
+		}
+	}
+}